@@ -0,0 +1,509 @@
+package addrbook
+
+import (
+	"errors"
+	"net"
+	"net/url"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/sap200/evochain/constants"
+)
+
+// Peer is what we know of a node of the network.
+type Peer struct {
+	Address   string `json:"address"`
+	Seed      bool   `json:"seed,omitempty"`    // Given in the config, never forgotten
+	Inbound   bool   `json:"inbound,omitempty"` // Contacted us first
+	Active    bool   `json:"active"`            // Answered our last ping and holds a slot
+	LastSeen  int64  `json:"last_seen"`         // In nanoseconds, zero if never
+	LastTried int64  `json:"last_tried"`        // In nanoseconds
+	Failures  int    `json:"failures"`          // Pings failed in a row
+}
+
+// Host is the misbehavior record of a host. Misbehavior is charged to the
+// host a connection comes from, or the one we connected to, never to an
+// address a node claims, so nodes sharing a host share a score.
+type Host struct {
+	Host        string `json:"host"`
+	Score       int    `json:"score"`                  // Banned at BAN_SCORE
+	BannedUntil int64  `json:"banned_until,omitempty"` // In nanoseconds
+}
+
+var ErrBadAddress = errors.New("node address is not http://host:port")
+
+// ParseAddress checks that address is the address of a node, http:// and a
+// host and port with nothing after them, and returns it written the one way
+// the book keys it by.
+func ParseAddress(address string) (string, error) {
+	u, err := url.Parse(address)
+	if err != nil || u.Scheme != "http" || u.User != nil || u.Opaque != "" || u.RawQuery != "" || u.Fragment != "" {
+		return "", ErrBadAddress
+	}
+	if u.Path != "" && u.Path != "/" {
+		return "", ErrBadAddress
+	}
+
+	port, err := strconv.ParseUint(u.Port(), 10, 16)
+	if u.Hostname() == "" || err != nil || port == 0 {
+		return "", ErrBadAddress
+	}
+
+	return "http://" + net.JoinHostPort(u.Hostname(), strconv.FormatUint(port, 10)), nil
+}
+
+// HostOf returns the host of a node address, empty if it has none.
+func HostOf(address string) string {
+	u, err := url.Parse(address)
+	if err != nil {
+		return ""
+	}
+	return u.Hostname()
+}
+
+// Book is the address book of a node. Addresses heard from peers are merged
+// into it, never replacing what we know. Peers answering our pings take an
+// outbound slot, or an inbound one when they contacted us first, up to a cap
+// for each. Peers failing too many pings in a row are forgotten, and hosts
+// misbehaving too much are banned for a while along with every peer on them.
+type Book struct {
+	mu          sync.Mutex
+	self        string
+	maxOutbound int
+	maxInbound  int
+	peers       map[string]*Peer
+	hosts       map[string]*Host
+}
+
+// New returns an empty address book for the node at address self.
+func New(self string, maxOutbound int, maxInbound int) *Book {
+	b := new(Book)
+	b.self = self
+	if address, err := ParseAddress(self); err == nil {
+		b.self = address
+	}
+	b.maxOutbound = maxOutbound
+	b.maxInbound = maxInbound
+	b.peers = map[string]*Peer{}
+	b.hosts = map[string]*Host{}
+	return b
+}
+
+// find returns the entry of address, if any.
+func (b *Book) find(address string) (*Peer, bool) {
+	address, err := ParseAddress(address)
+	if err != nil {
+		return nil, false
+	}
+
+	p, ok := b.peers[address]
+	return p, ok
+}
+
+// add returns the entry of a valid address and whether it was just created.
+// A full book makes room by evicting its worst entry.
+func (b *Book) add(address string, now int64) (*Peer, bool) {
+	address, err := ParseAddress(address)
+	if err != nil || address == b.self {
+		return nil, false
+	}
+
+	p, ok := b.peers[address]
+	if ok {
+		return p, false
+	}
+	if len(b.peers) >= constants.MAX_KNOWN_PEERS && !b.evict(now) {
+		return nil, false
+	}
+
+	p = &Peer{Address: address}
+	b.peers[address] = p
+	return p, true
+}
+
+// evict forgets the worst entry that is neither a seed nor active: one on a
+// banned host, else the one failing the most pings, else the one seen the
+// longest ago. It reports whether there was one to forget.
+func (b *Book) evict(now int64) bool {
+	candidates := []*Peer{}
+	banned := map[string]bool{}
+	for address, p := range b.peers {
+		if !p.Seed && !p.Active {
+			candidates = append(candidates, p)
+			banned[address] = b.banned(p, now)
+		}
+	}
+	if len(candidates) == 0 {
+		return false
+	}
+
+	sort.Slice(candidates, func(i, j int) bool {
+		a, c := candidates[i], candidates[j]
+		if banned[a.Address] != banned[c.Address] {
+			return banned[a.Address]
+		}
+		if a.Failures != c.Failures {
+			return a.Failures > c.Failures
+		}
+		if a.LastSeen != c.LastSeen {
+			return a.LastSeen < c.LastSeen
+		}
+		return a.Address < c.Address
+	})
+	delete(b.peers, candidates[0].Address)
+	return true
+}
+
+// bannedHost reports whether host is banned, lifting a ban that is over.
+func (b *Book) bannedHost(host string, now int64) bool {
+	h, ok := b.hosts[host]
+	if !ok || h.BannedUntil == 0 {
+		return false
+	}
+	if now >= h.BannedUntil {
+		delete(b.hosts, host)
+		return false
+	}
+	return true
+}
+
+// banned reports whether the host of p is banned.
+func (b *Book) banned(p *Peer, now int64) bool {
+	return b.bannedHost(HostOf(p.Address), now)
+}
+
+func (b *Book) activeCount(inbound bool) int {
+	count := 0
+	for _, p := range b.peers {
+		if p.Active && p.Inbound == inbound {
+			count++
+		}
+	}
+	return count
+}
+
+// Load merges saved entries into the book. Slots are taken again by the
+// next round of pings.
+func (b *Book) Load(peers []*Peer, hosts []*Host) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := time.Now().UnixNano()
+	for _, saved := range hosts {
+		if len(b.hosts) >= constants.MAX_SCORED_HOSTS {
+			break
+		}
+		h := *saved
+		b.hosts[h.Host] = &h
+	}
+
+	for _, saved := range peers {
+		p, _ := b.add(saved.Address, now)
+		if p == nil {
+			continue
+		}
+		seed := p.Seed
+		address := p.Address
+		*p = *saved
+		p.Address = address
+		p.Seed = p.Seed || seed
+		p.Active = false
+		p.Inbound = false
+	}
+}
+
+// AddSeed adds an address given in the config, which is never forgotten.
+func (b *Book) AddSeed(address string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	p, _ := b.add(address, time.Now().UnixNano())
+	if p != nil {
+		p.Seed = true
+	}
+}
+
+// Learn merges addresses heard from a peer into the book and returns how
+// many of them are new. Known addresses keep what we know of them, and
+// addresses that are not those of a node are left out.
+func (b *Book) Learn(addresses []string) int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if len(addresses) > constants.MAX_SHARED_PEERS {
+		addresses = addresses[:constants.MAX_SHARED_PEERS]
+	}
+
+	now := time.Now().UnixNano()
+	added := 0
+	for _, address := range addresses {
+		if _, created := b.add(address, now); created {
+			added++
+		}
+	}
+	return added
+}
+
+// Contacted records that the node at address sent us something and reports
+// whether to ping it for an inbound slot: it is not banned or active, was not
+// tried recently, and an inbound slot is free.
+func (b *Book) Contacted(address string) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := time.Now().UnixNano()
+	p, _ := b.add(address, now)
+	if p == nil || b.banned(p, now) || p.Active {
+		return false
+	}
+
+	p.Inbound = true
+	if b.activeCount(true) >= b.maxInbound {
+		return false
+	}
+	return now-p.LastTried >= int64(constants.PEER_RETRY_INTERVAL*time.Second)
+}
+
+// Good records that address answered our ping and reports whether it holds
+// a slot.
+func (b *Book) Good(address string) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := time.Now().UnixNano()
+	p, ok := b.find(address)
+	if !ok || b.banned(p, now) {
+		return false
+	}
+
+	p.LastSeen = now
+	p.LastTried = now
+	p.Failures = 0
+	if !p.Active {
+		limit := b.maxOutbound
+		if p.Inbound {
+			limit = b.maxInbound
+		}
+		p.Active = b.activeCount(p.Inbound) < limit
+	}
+	return p.Active
+}
+
+// Failed records that address did not answer our ping. It gives up its slot,
+// and is forgotten after MAX_PEER_FAILURES failures in a row unless it is a
+// seed.
+func (b *Book) Failed(address string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	p, ok := b.find(address)
+	if !ok {
+		return
+	}
+
+	p.LastTried = time.Now().UnixNano()
+	p.Failures++
+	p.Active = false
+	p.Inbound = false
+	if !p.Seed && p.Failures >= constants.MAX_PEER_FAILURES {
+		delete(b.peers, p.Address)
+	}
+}
+
+// Misbehaved adds points to the misbehavior score of host, and bans it for
+// BAN_DURATION once the score reaches BAN_SCORE. It reports whether the host
+// got banned.
+func (b *Book) Misbehaved(host string, points int) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := time.Now().UnixNano()
+	if host == "" || b.bannedHost(host, now) {
+		return false
+	}
+
+	h, ok := b.hosts[host]
+	if !ok {
+		if len(b.hosts) >= constants.MAX_SCORED_HOSTS {
+			b.evictHost(now)
+		}
+		h = &Host{Host: host}
+		b.hosts[host] = h
+	}
+	h.Score += points
+	if h.Score < constants.BAN_SCORE {
+		return false
+	}
+
+	h.BannedUntil = now + int64(constants.BAN_DURATION*time.Second)
+	for _, p := range b.peers {
+		if HostOf(p.Address) == host {
+			p.Active = false
+		}
+	}
+	return true
+}
+
+// evictHost forgets the record of the host with the lowest score, or the
+// ban ending first when every host is banned.
+func (b *Book) evictHost(now int64) {
+	// lifting the bans that are over may be enough
+	for host := range b.hosts {
+		b.bannedHost(host, now)
+	}
+	if len(b.hosts) < constants.MAX_SCORED_HOSTS {
+		return
+	}
+
+	hosts := make([]*Host, 0, len(b.hosts))
+	for _, h := range b.hosts {
+		hosts = append(hosts, h)
+	}
+
+	sort.Slice(hosts, func(i, j int) bool {
+		a, c := hosts[i], hosts[j]
+		if (a.BannedUntil == 0) != (c.BannedUntil == 0) {
+			return a.BannedUntil == 0
+		}
+		if a.Score != c.Score {
+			return a.Score < c.Score
+		}
+		if a.BannedUntil != c.BannedUntil {
+			return a.BannedUntil < c.BannedUntil
+		}
+		return a.Host < c.Host
+	})
+	delete(b.hosts, hosts[0].Host)
+}
+
+// Banned reports whether host is banned.
+func (b *Book) Banned(host string) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.bannedHost(host, time.Now().UnixNano())
+}
+
+// Active lists the peers holding a slot.
+func (b *Book) Active() []string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	active := []string{}
+	for address, p := range b.peers {
+		if p.Active {
+			active = append(active, address)
+		}
+	}
+	sort.Strings(active)
+	return active
+}
+
+// IsActive reports whether address is a peer holding a slot.
+func (b *Book) IsActive(address string) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	p, ok := b.find(address)
+	return ok && p.Active
+}
+
+// ToDial lists the peers to ping in the next round: the active ones, and
+// enough others to fill the free outbound slots, least failing and most
+// recently seen first. A peer that failed is retried after a wait growing
+// with its failures.
+func (b *Book) ToDial() []string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := time.Now().UnixNano()
+	dial := []string{}
+	candidates := []*Peer{}
+	for address, p := range b.peers {
+		if b.banned(p, now) {
+			continue
+		}
+		if p.Active {
+			dial = append(dial, address)
+			continue
+		}
+		if p.Inbound {
+			continue
+		}
+		if p.Failures > 0 && now-p.LastTried < int64(p.Failures)*int64(constants.PEER_RETRY_INTERVAL*time.Second) {
+			continue
+		}
+		candidates = append(candidates, p)
+	}
+
+	sort.Slice(candidates, func(i, j int) bool {
+		if candidates[i].Failures != candidates[j].Failures {
+			return candidates[i].Failures < candidates[j].Failures
+		}
+		if candidates[i].LastSeen != candidates[j].LastSeen {
+			return candidates[i].LastSeen > candidates[j].LastSeen
+		}
+		return candidates[i].Address < candidates[j].Address
+	})
+
+	free := b.maxOutbound - b.activeCount(false)
+	for i := 0; i < free && i < len(candidates); i++ {
+		dial = append(dial, candidates[i].Address)
+	}
+	return dial
+}
+
+// Shareable lists the addresses worth passing on to other nodes: the active
+// peers and the ones seen lately.
+func (b *Book) Shareable() []string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := time.Now().UnixNano()
+	since := now - int64(constants.PEER_SHARE_WINDOW*time.Second)
+	shareable := []string{}
+	for address, p := range b.peers {
+		if !b.banned(p, now) && (p.Active || p.LastSeen >= since) {
+			shareable = append(shareable, address)
+		}
+	}
+	sort.Strings(shareable)
+	if len(shareable) > constants.MAX_SHARED_PEERS {
+		shareable = shareable[:constants.MAX_SHARED_PEERS]
+	}
+	return shareable
+}
+
+// Peers returns a copy of every entry, in address order.
+func (b *Book) Peers() []*Peer {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	peers := make([]*Peer, 0, len(b.peers))
+	for _, p := range b.peers {
+		peer := *p
+		peers = append(peers, &peer)
+	}
+	sort.Slice(peers, func(i, j int) bool {
+		return peers[i].Address < peers[j].Address
+	})
+	return peers
+}
+
+// Hosts returns a copy of every misbehavior record, in host order.
+func (b *Book) Hosts() []*Host {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	hosts := make([]*Host, 0, len(b.hosts))
+	for _, h := range b.hosts {
+		host := *h
+		hosts = append(hosts, &host)
+	}
+	sort.Slice(hosts, func(i, j int) bool {
+		return hosts[i].Host < hosts[j].Host
+	})
+	return hosts
+}
@@ -0,0 +1,141 @@
+package addrbook
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/sap200/evochain/constants"
+)
+
+func TestParseAddress(t *testing.T) {
+	tests := []struct {
+		address string
+		want    string
+		wantErr bool
+	}{
+		{"http://10.0.0.1:5000", "http://10.0.0.1:5000", false},
+		{"http://10.0.0.1:5000/", "http://10.0.0.1:5000", false},
+		{"http://[::1]:5000", "http://[::1]:5000", false},
+		{"http://node.example:05000", "http://node.example:5000", false},
+		{"", "", true},
+		{"junk", "", true},
+		{"10.0.0.1:5000", "", true},
+		{"https://10.0.0.1:5000", "", true},
+		{"http://10.0.0.1", "", true},
+		{"http://10.0.0.1:0", "", true},
+		{"http://10.0.0.1:70000", "", true},
+		{"http://:5000", "", true},
+		{"http://10.0.0.1:5000/admin", "", true},
+		{"http://10.0.0.1:5000?x=1", "", true},
+		{"http://user@10.0.0.1:5000", "", true},
+	}
+	for _, tt := range tests {
+		got, err := ParseAddress(tt.address)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("ParseAddress(%q) = %q, %v, want %q, error %v", tt.address, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestLearnMergesOnlyNodeAddresses(t *testing.T) {
+	b := New("http://127.0.0.1:5000", 8, 8)
+
+	added := b.Learn([]string{"http://10.0.0.1:5000", "junk", "http://10.0.0.1:5000/", "http://127.0.0.1:5000", "ftp://10.0.0.2:21"})
+	if added != 1 {
+		t.Fatalf("Learn() = %d, want 1", added)
+	}
+
+	// a later list never replaces what we know
+	b.Learn([]string{})
+	if peers := b.Peers(); len(peers) != 1 || peers[0].Address != "http://10.0.0.1:5000" {
+		t.Fatalf("Peers() = %v, want only http://10.0.0.1:5000", peers)
+	}
+}
+
+func TestMisbehavedCreatesNoPeer(t *testing.T) {
+	b := New("http://127.0.0.1:5000", 8, 8)
+
+	b.Misbehaved("10.0.0.9", constants.INVALID_TXN_SCORE)
+	if peers := b.Peers(); len(peers) != 0 {
+		t.Fatalf("Peers() = %v, want none", peers)
+	}
+	if b.Misbehaved("", constants.BAN_SCORE) || len(b.Hosts()) != 1 {
+		t.Fatalf("Hosts() = %v, want only 10.0.0.9", b.Hosts())
+	}
+}
+
+func TestBanCoversEveryPeerOnTheHost(t *testing.T) {
+	b := New("http://127.0.0.1:5000", 8, 8)
+	b.Learn([]string{"http://10.0.0.1:5000", "http://10.0.0.1:5001", "http://10.0.0.2:5000"})
+	for _, address := range b.ToDial() {
+		b.Good(address)
+	}
+
+	if !b.Misbehaved("10.0.0.1", constants.BAN_SCORE) {
+		t.Fatal("Misbehaved() did not ban the host")
+	}
+
+	if active := b.Active(); len(active) != 1 || active[0] != "http://10.0.0.2:5000" {
+		t.Fatalf("Active() = %v, want only http://10.0.0.2:5000", active)
+	}
+	for _, address := range b.ToDial() {
+		if HostOf(address) == "10.0.0.1" {
+			t.Fatalf("ToDial() = %v, holds a peer on the banned host", b.ToDial())
+		}
+	}
+	if b.Good("http://10.0.0.1:5001") {
+		t.Fatal("Good() gave a slot to a peer on the banned host")
+	}
+}
+
+func TestFullBookEvictsWorstEntries(t *testing.T) {
+	b := New("http://127.0.0.1:5000", 8, 8)
+	addresses := []string{}
+	for i := 0; i < constants.MAX_KNOWN_PEERS; i++ {
+		addresses = append(addresses, fmt.Sprintf("http://10.0.%d.%d:5000", i/250, i%250+1))
+	}
+	for i := 0; i < len(addresses); i += constants.MAX_SHARED_PEERS {
+		b.Learn(addresses[i : i+constants.MAX_SHARED_PEERS])
+	}
+
+	seed, active, failing := addresses[0], addresses[1], addresses[500]
+	b.AddSeed(seed)
+	b.Good(active)
+	b.Failed(failing)
+
+	if added := b.Learn([]string{"http://10.9.0.1:5000"}); added != 1 {
+		t.Fatalf("Learn() into a full book = %d, want 1", added)
+	}
+	if _, ok := b.find(failing); ok {
+		t.Fatal("the failing peer was not the one evicted")
+	}
+
+	// with no failures left, the peer seen the longest ago goes
+	if added := b.Learn([]string{"http://10.9.0.2:5000"}); added != 1 {
+		t.Fatalf("Learn() into a full book = %d, want 1", added)
+	}
+	for _, address := range []string{seed, active, "http://10.9.0.1:5000"} {
+		if _, ok := b.find(address); !ok {
+			t.Fatalf("%s was evicted", address)
+		}
+	}
+	if len(b.Peers()) != constants.MAX_KNOWN_PEERS {
+		t.Fatalf("len(Peers()) = %d, want %d", len(b.Peers()), constants.MAX_KNOWN_PEERS)
+	}
+}
+
+func TestMisbehavedEvictsLowestScoreWhenFull(t *testing.T) {
+	b := New("http://127.0.0.1:5000", 8, 8)
+	for i := 0; i < constants.MAX_SCORED_HOSTS; i++ {
+		b.Misbehaved(fmt.Sprintf("10.0.%d.%d", i/250, i%250+1), constants.INVALID_TXN_SCORE*2)
+	}
+	b.Misbehaved("10.0.0.1", constants.BAN_SCORE)
+
+	b.Misbehaved("10.9.0.1", constants.INVALID_TXN_SCORE)
+	if len(b.Hosts()) != constants.MAX_SCORED_HOSTS {
+		t.Fatalf("len(Hosts()) = %d, want %d", len(b.Hosts()), constants.MAX_SCORED_HOSTS)
+	}
+	if !b.Banned("10.0.0.1") {
+		t.Fatal("a banned host was evicted")
+	}
+}
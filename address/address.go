@@ -0,0 +1,78 @@
+package address
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/sap200/evochain/constants"
+)
+
+var ErrBadPublicKey = errors.New("public key is not a point of P-256 written as 0x followed by its coordinates in hex")
+
+// PublicKeyHex writes a public key as 0x followed by its coordinates in hex,
+// unpadded as they always were, since addresses are derived from it.
+func PublicKeyHex(publicKey *ecdsa.PublicKey) string {
+	return fmt.Sprintf("%s%x%x", constants.HEX_PREFIX, publicKey.X, publicKey.Y)
+}
+
+// ParsePublicKeyHex reads a public key written by PublicKeyHex. Coordinates
+// with leading zeros are written shorter, so every split of the hex into two
+// coordinates of at most 64 characters is tried for a point on the curve.
+func ParsePublicKeyHex(publicKeyHex string) (*ecdsa.PublicKey, error) {
+	if len(publicKeyHex) < 2+2 || len(publicKeyHex) > 2+128 || publicKeyHex[:2] != constants.HEX_PREFIX {
+		return nil, ErrBadPublicKey
+	}
+	digits := publicKeyHex[2:]
+	for i := 0; i < len(digits); i++ {
+		if !isHexDigit(digits[i]) {
+			return nil, ErrBadPublicKey
+		}
+	}
+
+	for xLen := max(1, len(digits)-64); xLen <= min(64, len(digits)-1); xLen++ {
+		x, _ := new(big.Int).SetString(digits[:xLen], 16)
+		y, _ := new(big.Int).SetString(digits[xLen:], 16)
+		if elliptic.P256().IsOnCurve(x, y) {
+			return &ecdsa.PublicKey{Curve: elliptic.P256(), X: x, Y: y}, nil
+		}
+	}
+	return nil, ErrBadPublicKey
+}
+
+func isHexDigit(c byte) bool {
+	return '0' <= c && c <= '9' || 'a' <= c && c <= 'f' || 'A' <= c && c <= 'F'
+}
+
+// IsValid reports whether a is written the way addresses are derived: the
+// prefix followed by 40 lowercase hex digits.
+func IsValid(a string) bool {
+	if len(a) != len(constants.ADDRESS_PREFIX)+40 || a[:len(constants.ADDRESS_PREFIX)] != constants.ADDRESS_PREFIX {
+		return false
+	}
+	for i := len(constants.ADDRESS_PREFIX); i < len(a); i++ {
+		if !('0' <= a[i] && a[i] <= '9' || 'a' <= a[i] && a[i] <= 'f') {
+			return false
+		}
+	}
+	return true
+}
+
+// FromPublicKeyHex derives the address owning a public key: the prefix
+// followed by the last 20 bytes of the SHA-256 of the key's hex.
+func FromPublicKeyHex(publicKeyHex string) string {
+	if len(publicKeyHex) < 2 {
+		return ""
+	}
+
+	hash := sha256.Sum256([]byte(publicKeyHex[2:]))
+	hex := fmt.Sprintf("%x", hash[:])
+	return constants.ADDRESS_PREFIX + hex[len(hex)-40:]
+}
+
+func FromPublicKey(publicKey *ecdsa.PublicKey) string {
+	return FromPublicKeyHex(PublicKeyHex(publicKey))
+}
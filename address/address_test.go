@@ -0,0 +1,98 @@
+package address
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"strings"
+	"testing"
+)
+
+// keyWith returns a new key whose coordinates written in hex have xLen and
+// yLen characters.
+func keyWith(t *testing.T, xLen int, yLen int) *ecdsa.PublicKey {
+	t.Helper()
+
+	for i := 0; i < 100000; i++ {
+		key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(key.X.Text(16)) == xLen && len(key.Y.Text(16)) == yLen {
+			return &key.PublicKey
+		}
+	}
+	t.Fatalf("no key with coordinates of %d and %d hex characters", xLen, yLen)
+	return nil
+}
+
+func TestPublicKeyHexRoundTrip(t *testing.T) {
+	tests := []struct {
+		name string
+		xLen int
+		yLen int
+	}{
+		{"full coordinates", 64, 64},
+		{"short x", 63, 64},
+		{"short y", 64, 63},
+		{"both short", 63, 63},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			key := keyWith(t, tt.xLen, tt.yLen)
+			publicKeyHex := PublicKeyHex(key)
+			if want := "0x" + key.X.Text(16) + key.Y.Text(16); publicKeyHex != want {
+				t.Fatalf("PublicKeyHex() = %s, want the unpadded %s", publicKeyHex, want)
+			}
+
+			parsed, err := ParsePublicKeyHex(publicKeyHex)
+			if err != nil {
+				t.Fatalf("ParsePublicKeyHex() error = %v", err)
+			}
+			if !parsed.Equal(key) {
+				t.Fatal("ParsePublicKeyHex() returned another key")
+			}
+		})
+	}
+}
+
+func TestParsePublicKeyHexRejectsMalformedKeys(t *testing.T) {
+	key := PublicKeyHex(keyWith(t, 64, 64))
+	for _, publicKeyHex := range []string{
+		"",
+		"0x",
+		"0x1",
+		key[2:],
+		key + "0",
+		key[:len(key)-1],
+		key[:len(key)-1] + "g",
+		"0x" + "-" + key[3:],
+	} {
+		if _, err := ParsePublicKeyHex(publicKeyHex); err != ErrBadPublicKey {
+			t.Fatalf("ParsePublicKeyHex(%q) error = %v, want ErrBadPublicKey", publicKeyHex, err)
+		}
+	}
+}
+
+func TestIsValid(t *testing.T) {
+	derived := FromPublicKey(keyWith(t, 64, 64))
+	tests := []struct {
+		address string
+		want    bool
+	}{
+		{derived, true},
+		{"", false},
+		{derived[:len(derived)-1], false},
+		{derived + "0", false},
+		{derived + ":junk", false},
+		{"Evochain_Faucet", false},
+		{"evochain" + strings.Repeat("A", 40), false},
+		{"evochain" + strings.Repeat("g", 40), false},
+		{"tvochain" + strings.Repeat("0", 40), false},
+	}
+	for _, tt := range tests {
+		if got := IsValid(tt.address); got != tt.want {
+			t.Fatalf("IsValid(%q) = %v, want %v", tt.address, got, tt.want)
+		}
+	}
+}
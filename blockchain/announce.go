@@ -0,0 +1,100 @@
+package blockchain
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+
+	"github.com/sap200/evochain/addrbook"
+	"github.com/sap200/evochain/constants"
+)
+
+// BlockAnnouncement tells a peer about a new tip of our chain. The peer
+// fetches the block, and the parents it misses, from us.
+type BlockAnnouncement struct {
+	BlockHash   string `json:"block_hash"`
+	BlockNumber uint64 `json:"block_number"`
+	From        string `json:"from"` // Address of the announcing node
+}
+
+// SendBlockAnnouncement queues announcement for the peer at address.
+func (bc *BlockchainStruct) SendBlockAnnouncement(address string, announcement *BlockAnnouncement) {
+	data, err := json.Marshal(announcement)
+	if err != nil {
+		log.Println("Error while marshalling the block announcement:", err.Error())
+		return
+	}
+
+	bc.SeenBlocks.Add(announcement.BlockHash, address)
+	bc.Gossip.Send(address, "/announce_block", data)
+}
+
+// AnnounceBlocks announces every new tip of our chain, mined or taken from a
+// peer, to our peers not known to have it.
+func (bc *BlockchainStruct) AnnounceBlocks() {
+	for event := range bc.Events.Subscribe() {
+		if event.Type != constants.EVENT_NEW_TIP {
+			continue
+		}
+
+		announcement := &BlockAnnouncement{BlockHash: event.BlockHash, BlockNumber: event.BlockNumber, From: bc.Address}
+		for _, peer := range bc.activePeers() {
+			if !bc.SeenBlocks.Has(event.BlockHash, peer) {
+				bc.SendBlockAnnouncement(peer, announcement)
+			}
+		}
+	}
+}
+
+// HandleBlockAnnouncement fetches an announced block we do not have yet from
+// the announcing peer, along with the parents we miss, and processes them.
+// A block too far ahead for that is caught up with by a sync instead. Only
+// announcements from our active peers are followed, we never fetch from an
+// address a request hands us.
+func (bc *BlockchainStruct) HandleBlockAnnouncement(announcement *BlockAnnouncement) {
+	if !bc.Peers.IsActive(announcement.From) {
+		log.Println("Ignoring block announcement from", announcement.From, "which is not an active peer")
+		return
+	}
+
+	fresh := bc.SeenBlocks.Add(announcement.BlockHash, announcement.From)
+	if !fresh || HasBlockInDb(bc.Store, announcement.BlockHash) {
+		return
+	}
+
+	branch := []*Block{}
+	hash := announcement.BlockHash
+	for len(branch) < constants.MAX_ANNOUNCED_ANCESTORS {
+		b, err := FetchBlockByHash(announcement.From, hash)
+		if err != nil {
+			log.Println("Error while fetching announced block from the peer:", announcement.From, "Error:", err.Error())
+			bc.SeenBlocks.Remove(announcement.BlockHash)
+			return
+		}
+
+		branch = append(branch, b)
+		if HasBlockInDb(bc.Store, b.PrevHash) {
+			break
+		}
+		hash = b.PrevHash
+	}
+	for i, j := 0, len(branch)-1; i < j; i, j = i+1, j-1 {
+		branch[i], branch[j] = branch[j], branch[i]
+	}
+
+	if !HasBlockInDb(bc.Store, branch[0].PrevHash) {
+		log.Println("Announced block", announcement.BlockNumber, "is too far ahead, syncing from peers")
+		err := bc.SyncFromPeers()
+		if err != nil {
+			log.Println("Error while syncing from peers:", err.Error())
+		}
+		return
+	}
+
+	err := bc.ProcessBlocks(branch)
+	if errors.Is(err, ErrInvalidBlock) {
+		bc.PeerMisbehaved(addrbook.HostOf(announcement.From), constants.INVALID_BLOCK_SCORE, err.Error())
+	} else if err != nil {
+		log.Println("Error while processing announced block from the peer:", announcement.From, "Error:", err.Error())
+	}
+}
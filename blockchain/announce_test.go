@@ -0,0 +1,32 @@
+package blockchain
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+)
+
+func TestHandleBlockAnnouncementFetchesOnlyFromActivePeers(t *testing.T) {
+	bc := newTestChain(t, nil)
+
+	var requests atomic.Int32
+	peer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		requests.Add(1)
+		http.NotFound(w, req)
+	}))
+	defer peer.Close()
+
+	announcement := &BlockAnnouncement{BlockHash: "0x01", BlockNumber: 1, From: peer.URL}
+	bc.HandleBlockAnnouncement(announcement)
+	if n := requests.Load(); n != 0 {
+		t.Fatalf("fetched %d times from an address that is not a peer", n)
+	}
+
+	bc.Peers.Learn([]string{peer.URL})
+	bc.Peers.Good(peer.URL)
+	bc.HandleBlockAnnouncement(announcement)
+	if n := requests.Load(); n == 0 {
+		t.Fatal("did not fetch the block announced by an active peer")
+	}
+}
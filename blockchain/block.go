@@ -6,24 +6,47 @@ import (
 	"encoding/json"
 	"time"
 
+	"github.com/sap200/evochain/codec"
 	"github.com/sap200/evochain/constants"
+	"github.com/sap200/evochain/merkle"
 )
 
+// BlockHeader is everything the proof of work is computed over. The
+// transactions are committed through MerkleRoot.
+type BlockHeader struct {
+	Version     uint32 `json:"version"`
+	BlockNumber uint64 `json:"block_number"`
+	PrevHash    string `json:"prevHash"`
+	MerkleRoot  string `json:"merkle_root"`
+	Timestamp   int64  `json:"timestamp"`
+	Nonce       int    `json:"nonce"`
+	Target      string `json:"target"`
+}
+
 type Block struct {
-	BlockNumber  uint64         `json:"block_number"`
-	PrevHash     string         `json:"prevHash"`
-	Timestamp    int64          `json:"timestamp"`
-	Nonce        int            `json:"nonce"`
+	BlockHeader
 	Transactions []*Transaction `json:"transactions"`
 }
 
+type MerkleProof struct {
+	BlockNumber     uint64             `json:"block_number"`
+	BlockHash       string             `json:"block_hash"`
+	MerkleRoot      string             `json:"merkle_root"`
+	TransactionHash string             `json:"transaction_hash"`
+	Index           int                `json:"index"`
+	Proof           []merkle.ProofStep `json:"proof"`
+}
+
 func NewBlock(prevHash string, nonce int, blockNumber uint64) *Block {
 	block := new(Block)
+	block.Version = constants.BLOCK_VERSION
 	block.PrevHash = prevHash
 	block.Timestamp = time.Now().UnixNano()
 	block.Nonce = nonce
 	block.Transactions = []*Transaction{}
 	block.BlockNumber = blockNumber
+	block.Target = constants.INITIAL_TARGET
+	block.MerkleRoot = block.ComputeMerkleRoot()
 
 	return block
 }
@@ -38,23 +61,68 @@ func (b Block) ToJson() string {
 	}
 }
 
-func (b Block) Hash() string {
+// Encode is the canonical encoding of a header.
+func (h BlockHeader) Encode() []byte {
+	e := codec.NewEncoder(byte(h.Version))
+	e.PutUint64(h.BlockNumber)
+	e.PutString(h.PrevHash)
+	e.PutString(h.MerkleRoot)
+	e.PutInt64(h.Timestamp)
+	e.PutInt64(int64(h.Nonce))
+	e.PutString(h.Target)
+	return e.Bytes()
+}
 
-	bs, _ := json.Marshal(b)
-	sum := sha256.Sum256(bs)
+// Hash of a header is the hash of its canonical encoding.
+func (h BlockHeader) Hash() string {
+	sum := sha256.Sum256(h.Encode())
 	hexRep := hex.EncodeToString(sum[:32])
 	formattedHexRep := constants.HEX_PREFIX + hexRep
 
 	return formattedHexRep
 }
 
-func (b *Block) AddTransactionToTheBlock(txn *Transaction) {
-	// check if the txn verification is a success or a failure
-	if txn.Status == constants.TXN_VERIFICATION_SUCCESS {
-		txn.Status = constants.SUCCESS
-	} else {
-		txn.Status = constants.FAILED
+// Hash of a block is the hash of its header.
+func (b Block) Hash() string {
+	return b.BlockHeader.Hash()
+}
+
+// merkleLeaves are the hashes of the transactions computed from their
+// content, so the root commits to what the transactions say and not to the
+// hash they claim.
+func (b Block) merkleLeaves() [][]byte {
+	leaves := make([][]byte, len(b.Transactions))
+	for i, txn := range b.Transactions {
+		leaves[i] = []byte(txn.Hash())
+	}
+	return leaves
+}
+
+func (b Block) ComputeMerkleRoot() string {
+	return constants.HEX_PREFIX + hex.EncodeToString(merkle.Root(b.merkleLeaves()))
+}
+
+// GetMerkleProof proves that the transaction is committed to by the header.
+func (b Block) GetMerkleProof(txnHash string) (*MerkleProof, error) {
+	for i, txn := range b.Transactions {
+		if txn.TransactionHash != txnHash {
+			continue
+		}
+
+		proof, err := merkle.Proof(b.merkleLeaves(), i)
+		if err != nil {
+			return nil, err
+		}
+
+		mp := new(MerkleProof)
+		mp.BlockNumber = b.BlockNumber
+		mp.BlockHash = b.Hash()
+		mp.MerkleRoot = b.MerkleRoot
+		mp.TransactionHash = txnHash
+		mp.Index = i
+		mp.Proof = proof
+		return mp, nil
 	}
 
-	b.Transactions = append(b.Transactions, txn)
+	return nil, ErrNotFound
 }
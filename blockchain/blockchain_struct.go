@@ -2,123 +2,243 @@ package blockchain
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
 	"log"
-	"strings"
+	"math/big"
 	"sync"
+	"time"
 
+	"github.com/sap200/evochain/addrbook"
+	"github.com/sap200/evochain/config"
 	"github.com/sap200/evochain/constants"
+	"github.com/sap200/evochain/gossip"
+	"github.com/sap200/evochain/mempool"
 )
 
+// BlockchainStruct is the chain of a node. Only our tip and the headers the
+// next target is computed from are kept in memory, every other block is read
+// from the store. They and the account state in the store only change with
+// mu held for writing, by AddBlock and ProcessBlocks; every other exported
+// method holds it for reading while it looks at them. Unexported methods
+// expect the caller to hold mu. The address book, pool, events, rejections,
+// gossip and seen caches lock themselves.
+//
+// pending is the state of our tip with the pool applied on top. It is guarded
+// by pendingMu, or by mu held for writing.
 type BlockchainStruct struct {
-	TransactionPool []*Transaction  `json:"transaction_pool"`
-	Blocks          []*Block        `json:"block_chain"`
-	Address         string          `json:"address"`
-	Peers           map[string]bool `json:"peers"`
-	MiningLocked    bool            `json:"mining_locked"`
+	Address     string                      `json:"address"`
+	Peers       *addrbook.Book              `json:"-"`
+	Pool        *mempool.Pool[*Transaction] `json:"-"`
+	Store       Store                       `json:"-"`
+	Config      *config.Config              `json:"-"`
+	Params      *ChainParams                `json:"-"`
+	Events      *EventFeed                  `json:"-"`
+	Rejections  *RejectionLog               `json:"-"`
+	Gossip      *gossip.Gossip              `json:"-"`
+	SeenBlocks  *gossip.SeenCache           `json:"-"`
+	SeenTxns    *gossip.SeenCache           `json:"-"`
+	mu          sync.RWMutex
+	syncMu      sync.Mutex
+	pendingMu   sync.Mutex
+	pending     *AccountState
+	tip         *Block
+	headers     []BlockHeader // The last RetargetWindow+1 of our chain, up to tip
+	genesisHash string
 }
 
-var mutex sync.Mutex
-
-func NewBlockchain(genesisBlock Block, address string) *BlockchainStruct {
-	exists, _ := KeyExists()
+var (
+	ErrNoBlockchain = errors.New("no blockchain found in the data directory, run chain init first")
+	ErrStaleBlock   = errors.New("block does not extend our tip")
+)
 
+// InitBlockchain writes the genesis and its block into an empty store.
+func InitBlockchain(store Store, genesis *Genesis) error {
+	exists, err := HasBlockchain(store)
+	if err != nil {
+		return err
+	}
 	if exists {
-		blockchainStruct, err := GetBlockchain()
-		if err != nil {
-			panic(err.Error())
-		}
-		return blockchainStruct
-	} else {
-		blockchainStruct := new(BlockchainStruct)
-		blockchainStruct.TransactionPool = []*Transaction{}
-		blockchainStruct.Blocks = []*Block{}
-		blockchainStruct.Blocks = append(blockchainStruct.Blocks, &genesisBlock)
-		blockchainStruct.Address = address
-		blockchainStruct.Peers = map[string]bool{}
-		blockchainStruct.MiningLocked = false
-		err := PutIntoDb(*blockchainStruct)
+		return errors.New("the data directory already holds a blockchain")
+	}
+	err = genesis.Validate()
+	if err != nil {
+		return err
+	}
+
+	batch := store.NewBatch()
+	batch.PutJson([]byte(constants.GENESIS_KEY), genesis)
+	err = store.Write(batch)
+	if err != nil {
+		return err
+	}
+
+	return PutIntoDb(store, []*Block{genesis.Block()}, []*Transaction{})
+}
+
+func NewBlockchain(store Store, cfg *config.Config) (*BlockchainStruct, error) {
+	exists, err := HasBlockchain(store)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, ErrNoBlockchain
+	}
+
+	current, err := StateIsCurrent(store)
+	if err != nil {
+		return nil, err
+	}
+	if !current {
+		err = Reindex(store)
 		if err != nil {
-			panic(err.Error())
+			return nil, err
 		}
-		return blockchainStruct
 	}
-}
 
-func NewBlockchainFromSync(bc1 *BlockchainStruct, address string) *BlockchainStruct {
-	bc2 := bc1
-	bc2.Address = address
+	// the consensus parameters are those of the genesis, never the config's
+	genesis, err := GetGenesisFromDb(store)
+	if err == ErrNotFound {
+		return nil, errors.New("the data directory holds no genesis, run chain init again")
+	}
+	if err != nil {
+		return nil, err
+	}
+	err = genesis.ChainParams.Validate()
+	if err != nil {
+		return nil, fmt.Errorf("invalid chain parameters: %s", err.Error())
+	}
 
-	err := PutIntoDb(*bc2)
+	blockchainStruct, err := LoadBlockchain(store, &genesis.ChainParams)
 	if err != nil {
-		panic(err.Error())
+		return nil, err
 	}
+	if genesis.Block().Hash() != blockchainStruct.GenesisHash() {
+		return nil, errors.New("the genesis block does not match the stored genesis")
+	}
+	blockchainStruct.setupNode(cfg)
 
-	return bc2
-}
+	peers, hosts, err := GetAddressBookFromDb(store)
+	if err != nil {
+		return nil, err
+	}
+	blockchainStruct.Peers.Load(peers, hosts)
 
-func (bc BlockchainStruct) PeersToJson() []byte {
-	nb, _ := json.Marshal(bc.Peers)
+	txnPool, err := GetTransactionPoolFromDb(store)
+	if err != nil {
+		return nil, err
+	}
+	blockchainStruct.restorePool(txnPool)
 
-	return nb
+	return blockchainStruct, nil
 }
 
-func (bc BlockchainStruct) ToJson() string {
-	nb, err := json.Marshal(bc)
+func (bc *BlockchainStruct) setupNode(cfg *config.Config) {
+	bc.Config = cfg
+	bc.Events = NewEventFeed()
+	bc.Rejections = NewRejectionLog()
+	bc.Pool = mempool.New[*Transaction](cfg.MempoolMaxTxns, time.Duration(cfg.MempoolTxnTTL)*time.Second)
+	bc.Address = cfg.NodeAddress()
+	bc.Gossip = gossip.New(bc.Address)
+	bc.SeenBlocks = gossip.NewSeenCache(constants.SEEN_BLOCKS)
+	bc.SeenTxns = gossip.NewSeenCache(constants.SEEN_TXNS)
+	bc.Peers = addrbook.New(bc.Address, cfg.MaxOutboundPeers, cfg.MaxInboundPeers)
+	for _, peer := range cfg.SeedPeers {
+		bc.Peers.AddSeed(peer)
+	}
+	if cfg.RemoteNode != "" {
+		bc.Peers.AddSeed(cfg.RemoteNode)
+	}
+}
 
-	if err != nil {
-		return err.Error()
-	} else {
-		return string(nb)
+// PeersToJson is the peers list we send: our address and the ones worth
+// passing on, in the format of the legacy peers map.
+func (bc *BlockchainStruct) PeersToJson() []byte {
+	peersList := map[string]bool{bc.Address: true}
+	for _, peer := range bc.Peers.Shareable() {
+		peersList[peer] = true
 	}
+
+	nb, _ := json.Marshal(peersList)
+
+	return nb
 }
 
-func (bc *BlockchainStruct) AddBlock(b *Block) {
-	mutex.Lock()
-	defer mutex.Unlock()
+// AddBlock appends a block we mined on top of our tip.
+func (bc *BlockchainStruct) AddBlock(b *Block) error {
+	bc.mu.Lock()
+	defer bc.mu.Unlock()
 
-	m := map[string]bool{}
-	for _, txn := range b.Transactions {
-		m[txn.TransactionHash] = true
+	if b.PrevHash != bc.tip.Hash() {
+		return ErrStaleBlock
 	}
 
-	// remove txn from txn pool
-	newTxnPool := []*Transaction{}
-	for _, txn := range bc.TransactionPool {
-		_, ok := m[txn.TransactionHash]
-		if !ok {
-			newTxnPool = append(newTxnPool, txn)
-		}
+	parentWork, err := GetTotalWorkFromDb(bc.Store, b.PrevHash)
+	if err != nil {
+		panic(err.Error())
 	}
 
-	bc.TransactionPool = newTxnPool
-	bc.Blocks = append(bc.Blocks, b)
+	state := bc.tipState()
+	undo := state.ApplyBlockWithUndo(b)
 
-	// save the blockchain to our database
-	err := PutIntoDb(*bc)
+	// remove txn from txn pool
+	for _, txn := range b.Transactions {
+		bc.Pool.Remove(txn.TransactionHash)
+	}
+	bc.validPool(state)
+	bc.setTip(b, append(bc.headers, b.BlockHeader))
+
+	// save the block, the account state and the new txn pool to our database
+	batch := bc.Store.NewBatch()
+	batch.PutBlock(b)
+	batch.SetCanonical(b)
+	batch.SetTip(b)
+	batch.SetAccounts(state.Accounts)
+	batch.SetUndo(b, undo)
+	batch.SetTotalWork(b, new(big.Int).Add(parentWork, BlockWork(b)))
+	batch.SetStateTip(b)
+	batch.SetTransactionPool(bc.Pool.Txns())
+	err = bc.Store.Write(batch)
 	if err != nil {
 		panic(err.Error())
 	}
-}
 
-func (bc *BlockchainStruct) appendTransactionToTheTransactionPool(transaction *Transaction) {
-	mutex.Lock()
-	defer mutex.Unlock()
+	bc.Events.Publish(ChainEvent{Type: constants.EVENT_NEW_TIP, BlockHash: b.Hash(), BlockNumber: b.BlockNumber})
+	return nil
+}
 
-	bc.TransactionPool = append(bc.TransactionPool, transaction)
+// AddTransactionToTransactionPool admits transaction to the pool if it is
+// valid on top of the pool, or on top of the transactions of its sender
+// before the one it replaces, and broadcasts it. Its signature is verified
+// here once; from then on only its nonce and funds are checked again. An invalid transaction is
+// refused with one of the ErrTxn errors, one the pool has no room for with a
+// mempool error. A transaction already in the pool is accepted again without
+// being broadcast. origin is the peer the transaction came from, empty when a
+// client sent it, which it is not relayed back to.
+func (bc *BlockchainStruct) AddTransactionToTransactionPool(transaction *Transaction, origin string) error {
+	bc.mu.RLock()
+	defer bc.mu.RUnlock()
+
+	if bc.Pool.Has(transaction.TransactionHash) {
+		bc.SeenTxns.Add(transaction.TransactionHash, origin)
+		return nil
+	}
 
-	// save the blockchain to our database
-	err := PutIntoDb(*bc)
+	err := VerifyTransaction(transaction, bc.Params.ChainId)
 	if err != nil {
-		panic(err.Error())
+		log.Println("Transaction", transaction.TransactionHash, "rejected:", err.Error())
+		bc.Rejections.Add(transaction.TransactionHash, err.Error())
+		return err
 	}
-}
 
-func (bc *BlockchainStruct) AddTransactionToTransactionPool(transaction *Transaction) {
+	bc.pendingMu.Lock()
+	defer bc.pendingMu.Unlock()
 
-	for _, txn := range bc.TransactionPool {
-		if txn.TransactionHash == transaction.TransactionHash {
-			return
-		}
+	err = checkSender(bc.pendingSenderBefore(transaction.From, transaction.Nonce), transaction)
+	if err != nil {
+		log.Println("Transaction", transaction.TransactionHash, "rejected:", err.Error())
+		bc.Rejections.Add(transaction.TransactionHash, err.Error())
+		return err
 	}
 
 	log.Println("Adding txn to the Transaction pool")
@@ -127,6 +247,9 @@ func (bc *BlockchainStruct) AddTransactionToTransactionPool(transaction *Transac
 	newTxn.From = transaction.From
 	newTxn.To = transaction.To
 	newTxn.Value = transaction.Value
+	newTxn.Fee = transaction.Fee
+	newTxn.ChainId = transaction.ChainId
+	newTxn.Nonce = transaction.Nonce
 	newTxn.Data = transaction.Data
 	newTxn.Status = transaction.Status
 	newTxn.Timestamp = transaction.Timestamp
@@ -134,154 +257,239 @@ func (bc *BlockchainStruct) AddTransactionToTransactionPool(transaction *Transac
 	newTxn.PublicKey = transaction.PublicKey
 	newTxn.Signature = transaction.Signature
 
-	valid1 := transaction.VerifyTxn()
-
-	valid2 := bc.simulatedBalanceCheck(valid1, transaction)
-
-	if valid1 && valid2 {
-		transaction.Status = constants.TXN_VERIFICATION_SUCCESS
+	transaction.Status = constants.TXN_VERIFICATION_SUCCESS
+	replaced, evicted, err := bc.Pool.Add(transaction, time.Now())
+	if err != nil {
+		log.Println("Transaction", transaction.TransactionHash, "rejected:", err.Error())
+		bc.Rejections.Add(transaction.TransactionHash, err.Error())
+		return err
+	}
+	for _, txn := range replaced {
+		bc.Rejections.Add(txn.TransactionHash, "replaced by transaction "+transaction.TransactionHash)
+	}
+	for _, txn := range evicted {
+		log.Println("Evicted transaction", txn.TransactionHash, "from the full pool")
+		bc.Rejections.Add(txn.TransactionHash, "evicted from the full transaction pool")
+	}
+	if len(replaced) > 0 || len(evicted) > 0 {
+		// transactions were taken out from the middle of the pool
+		bc.validPool(bc.tipState())
 	} else {
-		transaction.Status = constants.TXN_VERIFICATION_FAILURE
+		bc.pending.applyTransfer(transaction)
 	}
 
-	transaction.PublicKey = ""
-
-	bc.appendTransactionToTheTransactionPool(transaction)
-
+	bc.SeenTxns.Add(transaction.TransactionHash, origin)
 	bc.BroadcastTransaction(newTxn)
+
+	return nil
 }
 
-func (bc *BlockchainStruct) simulatedBalanceCheck(valid1 bool, transaction *Transaction) bool {
-	balance := bc.CalculateTotalCrypto(transaction.From)
-	for _, txn := range bc.TransactionPool {
-		if transaction.From == txn.From && valid1 {
-			if balance >= txn.Value {
-				balance -= txn.Value
-			} else {
-				break
+// validPool re-executes the pool in order on top of state and drops the
+// transactions that are no longer valid, made stale by a chain update, and the
+// ones that waited too long. They are remembered as rejected. The result is
+// the new pending state.
+func (bc *BlockchainStruct) validPool(state *AccountState) {
+	for _, txn := range bc.Pool.Expire(time.Now()) {
+		log.Println("Transaction", txn.TransactionHash, "expired from the pool")
+		bc.Rejections.Add(txn.TransactionHash, "expired from the transaction pool")
+	}
+
+	state = state.Copy()
+	for _, txn := range bc.Pool.Txns() {
+		if !bc.Pool.Has(txn.TransactionHash) {
+			continue
+		}
+
+		err := state.checkFunds(txn)
+		if err != nil {
+			for _, dropped := range bc.Pool.Drop(txn.TransactionHash) {
+				log.Println("Dropping transaction", dropped.TransactionHash, "from the pool:", err.Error())
+				bc.Rejections.Add(dropped.TransactionHash, err.Error())
 			}
+			continue
 		}
+		state.applyTransfer(txn)
 	}
-
-	return balance >= transaction.Value
+	bc.pending = state
 }
 
-func (bc *BlockchainStruct) ProofOfWorkMining(minersAddress string) {
-	log.Println("Starting to Mine...")
-	// calculate the prevHash
-	nonce := 0
-	for {
-		if bc.MiningLocked {
-			continue
+// restorePool puts the transactions saved with the last block back in the
+// pool, keeping those still valid on top of our tip.
+func (bc *BlockchainStruct) restorePool(txnPool []*Transaction) {
+	now := time.Now()
+	for _, txn := range txnPool {
+		if VerifyTransaction(txn, bc.Params.ChainId) == nil {
+			bc.Pool.Add(txn, now)
 		}
+	}
+	bc.validPool(bc.tipState())
+}
 
-		prevHash := bc.Blocks[len(bc.Blocks)-1].Hash()
+// pendingSenderBefore is the pending account of from without its
+// transactions with a nonce of nonce or more, the account a replacement is
+// checked against.
+func (bc *BlockchainStruct) pendingSenderBefore(from string, nonce uint64) Account {
+	sender := bc.pending.GetAccount(from)
+	for _, txn := range bc.Pool.BySender(from) {
+		if txn.Nonce >= nonce {
+			sender.Balance += txn.Value + txn.Fee
+			sender.Nonce--
+		}
+	}
+	return sender
+}
 
-		if bc.MiningLocked {
+// blockTransactions picks the transactions of the next block from the pool,
+// best fee rate first while keeping the transactions of each sender in nonce
+// order, until the block is full. Each is re-executed against the state of
+// our tip so transactions made stale by a chain update are left out. The
+// coinbase paying the reward and the fees comes last.
+func (bc *BlockchainStruct) blockTransactions(minersAddress string) []*Transaction {
+	state := bc.tipState()
+
+	txns := []*Transaction{}
+	size := 0
+	fees := uint64(0)
+	skipped := map[string]bool{}
+	for _, txn := range bc.Pool.ByFeeRate() {
+		if len(txns) == bc.Config.MaxBlockTxns {
+			break
+		}
+		if skipped[txn.From] {
 			continue
 		}
 
-		// start with a nonce
-		// create a new block
-		guessBlock := NewBlock(prevHash, nonce, uint64(len(bc.Blocks)))
-
-		if bc.MiningLocked {
+		newTxn := new(Transaction)
+		newTxn.Data = txn.Data
+		newTxn.From = txn.From
+		newTxn.To = txn.To
+		newTxn.Timestamp = txn.Timestamp
+		newTxn.Value = txn.Value
+		newTxn.Fee = txn.Fee
+		newTxn.ChainId = txn.ChainId
+		newTxn.Nonce = txn.Nonce
+		newTxn.TransactionHash = txn.TransactionHash
+		newTxn.PublicKey = txn.PublicKey
+		newTxn.Signature = txn.Signature
+		newTxn.Status = constants.SUCCESS
+
+		// the later transactions of a sender cannot go without this one
+		txnSize := newTxn.Size()
+		if size+txnSize > bc.Config.MaxBlockSize || state.checkFunds(newTxn) != nil {
+			skipped[txn.From] = true
 			continue
 		}
-		// copy the transaction pool
-		for _, txn := range bc.TransactionPool {
+		state.ApplyTransaction(newTxn)
 
-			if bc.MiningLocked {
-				continue
-			}
+		txns = append(txns, newTxn)
+		size += txnSize
+		fees += newTxn.Fee
+	}
 
-			newTxn := new(Transaction)
-			newTxn.Data = txn.Data
-			newTxn.From = txn.From
-			newTxn.To = txn.To
-			newTxn.Status = txn.Status
-			newTxn.Timestamp = txn.Timestamp
-			newTxn.Value = txn.Value
-			newTxn.TransactionHash = txn.TransactionHash
-			newTxn.PublicKey = txn.PublicKey
-			newTxn.Signature = txn.Signature
-
-			guessBlock.AddTransactionToTheBlock(newTxn)
-		}
+	reward := bc.Params.BlockReward(bc.tip.BlockNumber + 1)
+	rewardTxn := NewTransaction(constants.BLOCKCHAIN_ADDRESS, minersAddress, reward+fees, 0, 0, []byte{})
+	rewardTxn.Status = constants.SUCCESS
+	txns = append(txns, rewardTxn)
 
-		if bc.MiningLocked {
-			continue
-		}
+	return txns
+}
+
+func (bc *BlockchainStruct) CalculateTotalCrypto(address string) uint64 {
+	bc.mu.RLock()
+	defer bc.mu.RUnlock()
 
-		rewardTxn := NewTransaction(constants.BLOCKCHAIN_ADDRESS, minersAddress, constants.MINING_REWARD, []byte{})
-		rewardTxn.Status = constants.SUCCESS
-		guessBlock.Transactions = append(guessBlock.Transactions, rewardTxn)
+	return bc.tipState().GetAccount(address).Balance
+}
 
-		if bc.MiningLocked {
-			continue
-		}
+// GetAccountNonce returns the number of transactions the address has
+// successfully sent on our chain, which is the nonce its next one must use.
+func (bc *BlockchainStruct) GetAccountNonce(address string) uint64 {
+	bc.mu.RLock()
+	defer bc.mu.RUnlock()
 
-		// guess the Hash
-		guessHash := guessBlock.Hash()
-		desiredHash := strings.Repeat("0", constants.MINING_DIFFICULTY)
-		ourSolutionHash := guessHash[2 : 2+constants.MINING_DIFFICULTY]
+	return bc.tipState().GetAccount(address).Nonce
+}
 
-		if bc.MiningLocked {
-			continue
-		}
+// tipState is the persisted account state, which is at our last block.
+func (bc *BlockchainStruct) tipState() *AccountState {
+	return NewStoreAccountState(bc.Store, bc.tip)
+}
 
-		if ourSolutionHash == desiredHash {
+// setTip makes b our tip. headers are those of our chain up to b, of which
+// the last RetargetWindow+1 are kept.
+func (bc *BlockchainStruct) setTip(b *Block, headers []BlockHeader) {
+	if uint64(len(headers)) > bc.Params.RetargetWindow+1 {
+		headers = headers[uint64(len(headers))-bc.Params.RetargetWindow-1:]
+	}
+	bc.tip = b
+	bc.headers = append([]BlockHeader{}, headers...)
+}
 
-			if !bc.MiningLocked {
-				bc.AddBlock(guessBlock)
-				log.Println("Mined block number:", guessBlock.BlockNumber)
-			}
-			nonce = 0
+// headersUpTo returns the headers of our chain up to block number, the last
+// RetargetWindow+1 of them, which is all NextTarget looks at. Those we keep
+// in memory are not read again.
+func (bc *BlockchainStruct) headersUpTo(number uint64) ([]BlockHeader, error) {
+	first := uint64(0)
+	if number > bc.Params.RetargetWindow {
+		first = number - bc.Params.RetargetWindow
+	}
+
+	kept := uint64(0)
+	if len(bc.headers) > 0 {
+		kept = bc.headers[0].BlockNumber
+	}
+	headers := []BlockHeader{}
+	for n := first; n <= number; n++ {
+		if n >= kept && n-kept < uint64(len(bc.headers)) {
+			headers = append(headers, bc.headers[n-kept])
 			continue
 		}
 
-		nonce++
+		b, err := bc.Store.GetBlockByNumber(n)
+		if err != nil {
+			return nil, err
+		}
+		headers = append(headers, b.BlockHeader)
 	}
-
+	return headers, nil
 }
 
-func (bc *BlockchainStruct) CalculateTotalCrypto(address string) uint64 {
-	sum := uint64(0)
-
-	for _, blocks := range bc.Blocks {
-		for _, txns := range blocks.Transactions {
-			if txns.Status == constants.SUCCESS {
-				if txns.To == address {
-					sum += txns.Value
-				} else if txns.From == address {
-					sum -= txns.Value
-				}
-			}
+// stateAt unwinds the account state from our tip down to block number
+// using the undo record of every block above it.
+func (bc *BlockchainStruct) stateAt(number uint64) *AccountState {
+	state := bc.tipState()
+	for b := bc.tip; b.BlockNumber > number; {
+		undo, err := GetUndoFromDb(bc.Store, b.Hash())
+		if err != nil {
+			panic(err.Error())
+		}
+		parent, err := bc.Store.GetBlock(b.PrevHash)
+		if err != nil {
+			panic(err.Error())
 		}
+		state.Revert(undo, parent)
+		b = parent
 	}
-	return sum
+	return state
 }
 
-func (bc *BlockchainStruct) GetAllTxns() []Transaction {
+// GetNextNonce is the account nonce plus the verified transactions of the
+// address still waiting in the transaction pool.
+func (bc *BlockchainStruct) GetNextNonce(address string) uint64 {
+	bc.mu.RLock()
+	defer bc.mu.RUnlock()
 
-	nTxns := []Transaction{}
+	bc.pendingMu.Lock()
+	defer bc.pendingMu.Unlock()
 
-	for i := len(bc.TransactionPool) - 1; i >= 0; i-- {
-		nTxns = append(nTxns, *bc.TransactionPool[i])
-	}
-
-	txns := []Transaction{}
+	return bc.pending.GetAccount(address).Nonce
+}
 
-	for _, blocks := range bc.Blocks {
-		for _, txn := range blocks.Transactions {
-			if txn.From != constants.BLOCKCHAIN_ADDRESS {
-				txns = append(txns, *txn)
-			}
-		}
-	}
-	for i := len(txns) - 1; i >= 0; i-- {
-		nTxns = append(nTxns, txns[i])
+func (bc *BlockchainStruct) GetMerkleProof(blockNumber uint64, txnHash string) (*MerkleProof, error) {
+	b, err := bc.Store.GetBlockByNumber(blockNumber)
+	if err != nil {
+		return nil, err
 	}
 
-	return nTxns
+	return b.GetMerkleProof(txnHash)
 }
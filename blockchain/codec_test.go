@@ -0,0 +1,85 @@
+package blockchain
+
+import (
+	"encoding/hex"
+	"fmt"
+	"testing"
+)
+
+const vectorTarget = "0x00000fffffffffffffffffffffffffffffffffffffffffffffffffffffffffff"
+
+// headerVectors pin the canonical header encoding and the hashes derived
+// from it. A change to any of them is a change of consensus.
+var headerVectors = []struct {
+	Header   BlockHeader
+	Encoding string
+	Hash     string
+}{
+	{
+		BlockHeader{Version: 1, BlockNumber: 0, PrevHash: "0x0", MerkleRoot: "0x", Timestamp: 1700000000000000000, Nonce: 0, Target: vectorTarget},
+		"0100000000000000000000000330783000000002307817979cfe362a0000000000000000000000000042307830303030306666666666666666666666666666666666666666666666666666666666666666666666666666666666666666666666666666666666666666666666",
+		"0x9ef33897de4328ef82afcae95b97a3c3152f82c3b4de14a87cc9c1c83b54d5a6",
+	},
+	{
+		BlockHeader{Version: 1, BlockNumber: 42, PrevHash: "0x00000a6b0b80a931c2cbef961af6f2171d92118a792e94bd52d8598ae3726a94", MerkleRoot: "0x7a0044bd17ec2ff7069faae6e99a5381fa430b0cff9e854813cf3862d6346943", Timestamp: 1792220682504058883, Nonce: 370317, Target: vectorTarget},
+		"01000000000000002a000000423078303030303061366230623830613933316332636265663936316166366632313731643932313138613739326539346264353264383539386165333732366139340000004230783761303034346264313765633266663730363966616165366539396135333831666134333062306366663965383534383133636633383632643633343639343318df3f3713c33803000000000005a68d00000042307830303030306666666666666666666666666666666666666666666666666666666666666666666666666666666666666666666666666666666666666666666666",
+		"0x975450d478860d36e17e3248fee13fa60424b963a8987d1bebb861383e06fc0c",
+	},
+}
+
+// txnVectors pin the canonical encoding of the signed part of transactions.
+// The second vector differs from the first only in fields the node sets or
+// that carry the signature, so it must hash the same.
+var txnVectors = []struct {
+	Txn         Transaction
+	Encoding    string
+	Hash        string
+	SigningHash string
+}{
+	{
+		Transaction{ChainId: 1, From: "evochain3dd025e8fec7eda7cdd012ddde9c8e978ee7fa33", To: "evochain4c5756faf0c45cc4d1a32e47def1485d0a87f0bf", Value: 1000, Fee: 10, Nonce: 3, Timestamp: 1700000000000000000},
+		"0100000000000000010000003065766f636861696e336464303235653866656337656461376364643031326464646539633865393738656537666133330000003065766f636861696e3463353735366661663063343563633464316133326534376465663134383564306138376630626600000000000003e8000000000000000a00000000000000030000000017979cfe362a0000",
+		"0x1b678bcec2c8b3514f630dd898b79d9156a5a359cc0e2bade3531c9103cdc860",
+		"c0acd41688bc9d632780faa1e5696d07cef12920ea95ed68cbe5f71e30b076aa",
+	},
+	{
+		Transaction{ChainId: 1, From: "evochain3dd025e8fec7eda7cdd012ddde9c8e978ee7fa33", To: "evochain4c5756faf0c45cc4d1a32e47def1485d0a87f0bf", Value: 1000, Fee: 10, Nonce: 3, Timestamp: 1700000000000000000, Data: []byte{}, Status: "success", PublicKey: "0x01", Signature: []byte{1, 2}, TransactionHash: "0xff"},
+		"0100000000000000010000003065766f636861696e336464303235653866656337656461376364643031326464646539633865393738656537666133330000003065766f636861696e3463353735366661663063343563633464316133326534376465663134383564306138376630626600000000000003e8000000000000000a00000000000000030000000017979cfe362a0000",
+		"0x1b678bcec2c8b3514f630dd898b79d9156a5a359cc0e2bade3531c9103cdc860",
+		"c0acd41688bc9d632780faa1e5696d07cef12920ea95ed68cbe5f71e30b076aa",
+	},
+	{
+		Transaction{From: "Evochain_Faucet", To: "evochain3dd025e8fec7eda7cdd012ddde9c8e978ee7fa33", Value: 120000, Data: []byte("hi"), Timestamp: 1700000000000000000},
+		"0100000000000000000000000f45766f636861696e5f4661756365740000003065766f636861696e33646430323565386665633765646137636464303132646464653963386539373865653766613333000000000001d4c00000000000000000000000000000000000000002686917979cfe362a0000",
+		"0xe673b5cbe1d609c51accf7ef848e5f3084e91b3ce8e7491f164f744b7436117d",
+		"ae295e0bf5cc47c953312372b24ec2d275e6a1e76733592649f0ab6d7c221f26",
+	},
+}
+
+func TestEncodingVectors(t *testing.T) {
+	for i, v := range headerVectors {
+		t.Run(fmt.Sprintf("header %d", i), func(t *testing.T) {
+			if got := hex.EncodeToString(v.Header.Encode()); got != v.Encoding {
+				t.Fatalf("Encode() = %s, want %s", got, v.Encoding)
+			}
+			if got := v.Header.Hash(); got != v.Hash {
+				t.Fatalf("Hash() = %s, want %s", got, v.Hash)
+			}
+		})
+	}
+
+	for i, v := range txnVectors {
+		t.Run(fmt.Sprintf("transaction %d", i), func(t *testing.T) {
+			if got := hex.EncodeToString(v.Txn.Encode()); got != v.Encoding {
+				t.Fatalf("Encode() = %s, want %s", got, v.Encoding)
+			}
+			if got := v.Txn.Hash(); got != v.Hash {
+				t.Fatalf("Hash() = %s, want %s", got, v.Hash)
+			}
+			signingHash := v.Txn.SigningHash()
+			if got := hex.EncodeToString(signingHash[:]); got != v.SigningHash {
+				t.Fatalf("SigningHash() = %s, want %s", got, v.SigningHash)
+			}
+		})
+	}
+}
@@ -1,59 +1,575 @@
 package blockchain
 
 import (
+	"encoding/binary"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
 
+	"github.com/sap200/evochain/addrbook"
 	"github.com/sap200/evochain/constants"
-	"github.com/syndtr/goleveldb/leveldb"
 )
 
-func PutIntoDb(bs BlockchainStruct) error {
-	db, err := leveldb.OpenFile(constants.BLOCKCHAIN_DB_PATH, nil)
+var ErrNotFound = errors.New("key not found in the store")
+
+type TransactionLocation struct {
+	BlockHash   string `json:"block_hash"`
+	BlockNumber uint64 `json:"block_number"`
+	Index       int    `json:"index"`
+}
+
+// Store is the persistence layer of a node. Blocks are kept by hash, the
+// canonical chain by number and transactions by hash. All writes that have to
+// land together go through a Batch.
+type Store interface {
+	Get(key []byte) ([]byte, error)
+	Has(key []byte) (bool, error)
+	GetBlock(hash string) (*Block, error)
+	GetBlockByNumber(number uint64) (*Block, error)
+	GetTransactionLocation(hash string) (*TransactionLocation, error)
+	GetTip() (*Block, error)
+	PutBlock(b *Block) error
+	IterateBlocks(from uint64, fn func(b *Block) bool) error
+	IteratePrefix(prefix []byte, fn func(key, value []byte) bool) error
+	IteratePrefixFrom(start []byte, prefix []byte, fn func(key, value []byte) bool) error
+	NewBatch() *Batch
+	Write(batch *Batch) error
+	Close() error
+}
+
+// kvBackend is the raw key value storage a Store is built on.
+type kvBackend interface {
+	get(key []byte) ([]byte, error)
+	has(key []byte) (bool, error)
+	write(ops []batchOp) error
+	iterate(start []byte, prefix []byte, fn func(key, value []byte) bool) error
+	close() error
+}
+
+type batchOp struct {
+	key    []byte
+	value  []byte
+	delete bool
+}
+
+type Batch struct {
+	ops []batchOp
+	err error
+}
+
+func (batch *Batch) Put(key, value []byte) {
+	batch.ops = append(batch.ops, batchOp{key: key, value: value})
+}
+
+func (batch *Batch) Delete(key []byte) {
+	batch.ops = append(batch.ops, batchOp{key: key, delete: true})
+}
+
+func (batch *Batch) PutJson(key []byte, v interface{}) {
+	value, err := json.Marshal(v)
+	if err != nil {
+		batch.err = err
+		return
+	}
+	batch.Put(key, value)
+}
+
+// PutBlock stores the block under its hash only.
+func (batch *Batch) PutBlock(b *Block) {
+	batch.PutJson(blockHashKey(b.Hash()), b)
+}
+
+// SetCanonical makes b the block for its number and indexes its transactions
+// by hash and by address.
+func (batch *Batch) SetCanonical(b *Block) {
+	hash := b.Hash()
+	batch.Put(blockNumberKey(b.BlockNumber), []byte(hash))
+	for i, txn := range b.Transactions {
+		batch.PutJson(txnKey(txn.TransactionHash), TransactionLocation{hash, b.BlockNumber, i})
+		for _, address := range txnAddresses(txn) {
+			batch.Put(addressTxnKey(address, b.BlockNumber, i), []byte(txn.TransactionHash))
+		}
+	}
+}
+
+func (batch *Batch) UnsetCanonical(b *Block) {
+	batch.Delete(blockNumberKey(b.BlockNumber))
+	for i, txn := range b.Transactions {
+		batch.Delete(txnKey(txn.TransactionHash))
+		for _, address := range txnAddresses(txn) {
+			batch.Delete(addressTxnKey(address, b.BlockNumber, i))
+		}
+	}
+}
+
+func (batch *Batch) SetTip(tip *Block) {
+	batch.Put([]byte(constants.TIP_KEY), []byte(tip.Hash()))
+	batch.Put([]byte(constants.HEIGHT_KEY), encodeUint64(tip.BlockNumber))
+}
+
+// SetAccounts writes the accounts of a state, zero accounts are deleted.
+func (batch *Batch) SetAccounts(accounts map[string]*Account) {
+	for address, account := range accounts {
+		if *account == (Account{}) {
+			batch.Delete(accountKey(address))
+		} else {
+			batch.PutJson(accountKey(address), account)
+		}
+	}
+}
+
+func (batch *Batch) SetUndo(b *Block, undo map[string]Account) {
+	batch.PutJson(undoKey(b.Hash()), undo)
+}
+
+// SetTotalWork records the cumulative proof of work of the chain ending at b.
+func (batch *Batch) SetTotalWork(b *Block, totalWork *big.Int) {
+	batch.Put(totalWorkKey(b.Hash()), []byte(totalWork.String()))
+}
+
+func (batch *Batch) SetStateTip(b *Block) {
+	batch.Put([]byte(constants.STATE_TIP_KEY), []byte(b.Hash()))
+}
+
+func (batch *Batch) SetTransactionPool(txnPool []*Transaction) {
+	batch.PutJson([]byte(constants.TXN_POOL_KEY), txnPool)
+}
+
+func (batch *Batch) SetAddressBook(peers []*addrbook.Peer, hosts []*addrbook.Host) {
+	batch.PutJson([]byte(constants.ADDRESS_BOOK_KEY), peers)
+	batch.PutJson([]byte(constants.PEER_HOSTS_KEY), hosts)
+}
+
+type kvStore struct {
+	backend kvBackend
+}
+
+func (s *kvStore) Get(key []byte) ([]byte, error) {
+	return s.backend.get(key)
+}
+
+func (s *kvStore) Has(key []byte) (bool, error) {
+	return s.backend.has(key)
+}
+
+func (s *kvStore) getJson(key []byte, v interface{}) error {
+	data, err := s.backend.get(key)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+func (s *kvStore) GetBlock(hash string) (*Block, error) {
+	var b Block
+	err := s.getJson(blockHashKey(hash), &b)
+	if err != nil {
+		return nil, err
+	}
+	return &b, nil
+}
+
+func (s *kvStore) GetBlockByNumber(number uint64) (*Block, error) {
+	hash, err := s.backend.get(blockNumberKey(number))
+	if err != nil {
+		return nil, err
+	}
+	return s.GetBlock(string(hash))
+}
+
+func (s *kvStore) GetTransactionLocation(hash string) (*TransactionLocation, error) {
+	var loc TransactionLocation
+	err := s.getJson(txnKey(hash), &loc)
+	if err != nil {
+		return nil, err
+	}
+	return &loc, nil
+}
+
+func (s *kvStore) GetTip() (*Block, error) {
+	hash, err := s.backend.get([]byte(constants.TIP_KEY))
+	if err != nil {
+		return nil, err
+	}
+	return s.GetBlock(string(hash))
+}
+
+func (s *kvStore) PutBlock(b *Block) error {
+	batch := s.NewBatch()
+	batch.PutBlock(b)
+	return s.Write(batch)
+}
+
+func (s *kvStore) IterateBlocks(from uint64, fn func(b *Block) bool) error {
+	var innerErr error
+	err := s.backend.iterate(blockNumberKey(from), []byte(constants.BLOCK_NUMBER_KEY_PREFIX), func(key, value []byte) bool {
+		b, err := s.GetBlock(string(value))
+		if err != nil {
+			innerErr = err
+			return false
+		}
+		return fn(b)
+	})
+	if err != nil {
+		return err
+	}
+	return innerErr
+}
+
+func (s *kvStore) IteratePrefix(prefix []byte, fn func(key, value []byte) bool) error {
+	return s.backend.iterate(prefix, prefix, fn)
+}
+
+func (s *kvStore) IteratePrefixFrom(start []byte, prefix []byte, fn func(key, value []byte) bool) error {
+	return s.backend.iterate(start, prefix, fn)
+}
+
+func (s *kvStore) NewBatch() *Batch {
+	return new(Batch)
+}
+
+func (s *kvStore) Write(batch *Batch) error {
+	if batch.err != nil {
+		return batch.err
+	}
+	return s.backend.write(batch.ops)
+}
+
+func (s *kvStore) Close() error {
+	return s.backend.close()
+}
+
+func blockHashKey(hash string) []byte {
+	return []byte(constants.BLOCK_HASH_KEY_PREFIX + hash)
+}
+
+func blockNumberKey(number uint64) []byte {
+	key := make([]byte, len(constants.BLOCK_NUMBER_KEY_PREFIX)+8)
+	copy(key, constants.BLOCK_NUMBER_KEY_PREFIX)
+	binary.BigEndian.PutUint64(key[len(constants.BLOCK_NUMBER_KEY_PREFIX):], number)
+	return key
+}
+
+func txnKey(hash string) []byte {
+	return []byte(constants.TXN_KEY_PREFIX + hash)
+}
+
+func addressTxnPrefix(address string) []byte {
+	return []byte(constants.ADDRESS_TXN_KEY_PREFIX + address + ":")
+}
+
+// addressTxnKey stores the block number and index inverted so that iterating
+// the keys of an address walks its transactions from the newest.
+func addressTxnKey(address string, number uint64, index int) []byte {
+	key := addressTxnPrefix(address)
+	key = append(key, encodeUint64(^number)...)
+	key = binary.BigEndian.AppendUint32(key, ^uint32(index))
+	return key
+}
+
+func txnAddresses(txn *Transaction) []string {
+	if txn.From == txn.To {
+		return []string{txn.From}
+	}
+	return []string{txn.From, txn.To}
+}
+
+func legacyBlockKey(number uint64) []byte {
+	return append([]byte(constants.LEGACY_BLOCK_KEY_PREFIX), encodeUint64(number)...)
+}
+
+func accountKey(address string) []byte {
+	return []byte(constants.ACCOUNT_KEY_PREFIX + address)
+}
+
+func undoKey(hash string) []byte {
+	return []byte(constants.UNDO_KEY_PREFIX + hash)
+}
+
+func totalWorkKey(hash string) []byte {
+	return []byte(constants.TOTAL_WORK_KEY_PREFIX + hash)
+}
+
+func encodeUint64(n uint64) []byte {
+	bs := make([]byte, 8)
+	binary.BigEndian.PutUint64(bs, n)
+	return bs
+}
+
+// PutIntoDb writes every block and the transaction pool in one batch. Used
+// when a whole chain is obtained at once (genesis).
+func PutIntoDb(store Store, blocks []*Block, txnPool []*Transaction) error {
+	if len(blocks) == 0 {
+		return errors.New("cannot store a blockchain without blocks")
+	}
+
+	batch := store.NewBatch()
+	for _, b := range blocks {
+		batch.PutBlock(b)
+		batch.SetCanonical(b)
+	}
+	batch.SetTip(blocks[len(blocks)-1])
+	batch.SetTransactionPool(txnPool)
+
+	return store.Write(batch)
+}
+
+// migrateLegacyBlob rewrites a database holding the whole blockchain as one
+// JSON value under BLOCKCHAIN_KEY into separate keys. Its blocks were hashed
+// and mined under rules no release validates, so they cannot become our
+// chain: each is kept as it was under its own key, and the peers it knew go
+// to the address book for the node to sync the chain again from. The pending
+// transactions were signed without a chain id and are dropped.
+func migrateLegacyBlob(store Store) error {
+	data, err := store.Get([]byte(constants.BLOCKCHAIN_KEY))
+	if err == ErrNotFound {
+		return nil
+	}
 	if err != nil {
 		return err
 	}
-	defer db.Close()
 
-	// save into the database
-	value, err := json.Marshal(bs)
+	log.Println("Migrating the database from the single blob layout")
+
+	var legacy struct {
+		Blocks []json.RawMessage `json:"block_chain"`
+		Peers  map[string]bool   `json:"peers"`
+	}
+	err = json.Unmarshal(data, &legacy)
 	if err != nil {
 		return err
 	}
-	err = db.Put([]byte(constants.BLOCKCHAIN_KEY), value, nil)
+
+	batch := store.NewBatch()
+	for number, b := range legacy.Blocks {
+		batch.Put(legacyBlockKey(uint64(number)), b)
+	}
+	peers := []*addrbook.Peer{}
+	for address := range legacy.Peers {
+		peers = append(peers, &addrbook.Peer{Address: address})
+	}
+	batch.SetAddressBook(peers, []*addrbook.Host{})
+	batch.Delete([]byte(constants.BLOCKCHAIN_KEY))
+	err = store.Write(batch)
 	if err != nil {
 		return err
 	}
 
+	log.Println("Kept", len(legacy.Blocks), "legacy blocks and", len(peers), "peers, the chain has to be initialized and synced again")
 	return nil
 }
 
-func GetBlockchain() (*BlockchainStruct, error) {
-	db, err := leveldb.OpenFile(constants.BLOCKCHAIN_DB_PATH, nil)
+// LoadBlockchain reads our tip and the headers below it the next target is
+// computed from, the rest of the chain stays in the store.
+func LoadBlockchain(store Store, params *ChainParams) (*BlockchainStruct, error) {
+	bc := new(BlockchainStruct)
+	bc.Store = store
+	bc.Params = params
+
+	genesis, err := store.GetBlockByNumber(0)
 	if err != nil {
 		return nil, err
 	}
-	defer db.Close()
-	data, err := db.Get([]byte(constants.BLOCKCHAIN_KEY), nil)
+	bc.genesisHash = genesis.Hash()
+
+	tip, err := store.GetTip()
+	if err != nil {
+		return nil, err
+	}
+	headers, err := bc.headersUpTo(tip.BlockNumber)
+	if err != nil {
+		return nil, err
+	}
+	bc.setTip(tip, headers)
+
+	return bc, nil
+}
+
+// GetAddressBookFromDb returns the saved address book, its peers and the
+// misbehavior records of their hosts.
+func GetAddressBookFromDb(store Store) ([]*addrbook.Peer, []*addrbook.Host, error) {
+	peers := []*addrbook.Peer{}
+	hosts := []*addrbook.Host{}
+	data, err := store.Get([]byte(constants.PEER_HOSTS_KEY))
+	if err == nil {
+		err = json.Unmarshal(data, &hosts)
+	}
+	if err != nil && err != ErrNotFound {
+		return nil, nil, err
+	}
+
+	data, err = store.Get([]byte(constants.ADDRESS_BOOK_KEY))
+	if err == nil {
+		err = json.Unmarshal(data, &peers)
+	}
+	if err != nil && err != ErrNotFound {
+		return nil, nil, err
+	}
+	return peers, hosts, nil
+}
+
+// GetTransactionPoolFromDb returns the transaction pool saved with the last
+// block.
+func GetTransactionPoolFromDb(store Store) ([]*Transaction, error) {
+	txnPool := []*Transaction{}
+	data, err := store.Get([]byte(constants.TXN_POOL_KEY))
+	if err == ErrNotFound {
+		return txnPool, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	err = json.Unmarshal(data, &txnPool)
+	return txnPool, err
+}
+
+func HasBlockchain(store Store) (bool, error) {
+	return store.Has([]byte(constants.TIP_KEY))
+}
+
+func GetAccountFromDb(store Store, address string) (Account, error) {
+	var account Account
+	data, err := store.Get(accountKey(address))
+	if err == ErrNotFound {
+		return account, nil
+	}
+	if err != nil {
+		return account, err
+	}
+
+	err = json.Unmarshal(data, &account)
+	return account, err
+}
+
+// SumBalancesFromDb adds up the balance of every account.
+func SumBalancesFromDb(store Store) (uint64, error) {
+	total := uint64(0)
+	var innerErr error
+	err := store.IteratePrefix([]byte(constants.ACCOUNT_KEY_PREFIX), func(key, value []byte) bool {
+		var account Account
+		innerErr = json.Unmarshal(value, &account)
+		total += account.Balance
+		return innerErr == nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return total, innerErr
+}
+
+func GetUndoFromDb(store Store, hash string) (map[string]Account, error) {
+	data, err := store.Get(undoKey(hash))
 	if err != nil {
 		return nil, err
 	}
 
-	var bc BlockchainStruct
-	err = json.Unmarshal(data, &bc)
+	undo := map[string]Account{}
+	err = json.Unmarshal(data, &undo)
+	return undo, err
+}
+
+func GetTotalWorkFromDb(store Store, hash string) (*big.Int, error) {
+	data, err := store.Get(totalWorkKey(hash))
 	if err != nil {
 		return nil, err
 	}
 
-	return &bc, nil
+	totalWork, ok := new(big.Int).SetString(string(data), 10)
+	if !ok {
+		return nil, fmt.Errorf("malformed total work for block %s", hash)
+	}
+	return totalWork, nil
+}
+
+func HasBlockInDb(store Store, hash string) bool {
+	exists, err := store.Has(blockHashKey(hash))
+	if err != nil {
+		panic(err.Error())
+	}
+	return exists
 }
 
-func KeyExists() (bool, error) {
-	db, err := leveldb.OpenFile(constants.BLOCKCHAIN_DB_PATH, nil)
+// StateIsCurrent reports whether the persisted account state and total work
+// are at the tip of the persisted chain and the indexes are of the current
+// version.
+func StateIsCurrent(store Store) (bool, error) {
+	stateTip, err := store.Get([]byte(constants.STATE_TIP_KEY))
+	if err == ErrNotFound {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	tip, err := store.Get([]byte(constants.TIP_KEY))
 	if err != nil {
 		return false, err
 	}
-	defer db.Close()
 
-	exists, err := db.Has([]byte(constants.BLOCKCHAIN_KEY), nil)
-	return exists, err
+	if string(stateTip) != string(tip) {
+		return false, nil
+	}
+
+	hasWork, err := store.Has(totalWorkKey(string(tip)))
+	if err != nil || !hasWork {
+		return false, err
+	}
+
+	version, err := store.Get([]byte(constants.INDEX_VERSION_KEY))
+	if err == ErrNotFound {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return binary.BigEndian.Uint64(version) == constants.INDEX_VERSION, nil
+}
+
+// Reindex throws away the persisted account state and rebuilds it, with the
+// undo record and total work of every block and the transaction indexes, by
+// replaying the canonical chain.
+func Reindex(store Store) error {
+	log.Println("Rebuilding the account state from the blocks")
+
+	batch := store.NewBatch()
+	for _, prefix := range []string{constants.ACCOUNT_KEY_PREFIX, constants.UNDO_KEY_PREFIX, constants.ADDRESS_TXN_KEY_PREFIX} {
+		err := store.IteratePrefix([]byte(prefix), func(key, value []byte) bool {
+			batch.Delete(append([]byte{}, key...))
+			return true
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	state := NewAccountState()
+	totalWork := big.NewInt(0)
+	err := store.IterateBlocks(0, func(b *Block) bool {
+		batch.SetCanonical(b)
+		batch.SetUndo(b, state.ApplyBlockWithUndo(b))
+		totalWork = new(big.Int).Add(totalWork, BlockWork(b))
+		batch.SetTotalWork(b, totalWork)
+		return true
+	})
+	if err != nil {
+		return err
+	}
+	if state.Parent == nil {
+		return ErrNoBlockchain
+	}
+
+	batch.SetAccounts(state.Accounts)
+	batch.SetStateTip(state.Parent)
+	batch.Put([]byte(constants.INDEX_VERSION_KEY), encodeUint64(constants.INDEX_VERSION))
+
+	err = store.Write(batch)
+	if err != nil {
+		return err
+	}
+
+	log.Println("Account state rebuilt up to block", state.Parent.BlockNumber)
+	return nil
 }
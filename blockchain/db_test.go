@@ -0,0 +1,64 @@
+package blockchain
+
+import (
+	"testing"
+
+	"github.com/sap200/evochain/constants"
+)
+
+func TestOpenLevelDbStoreMigratesLegacyBlob(t *testing.T) {
+	path := t.TempDir()
+	store, err := OpenLevelDbStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	blob := `{"block_chain":[{"blockNumber":0,"prevHash":"0x0"},{"blockNumber":1,"prevHash":"0x00000abc"}],` +
+		`"transaction_pool":[],"peers":{"http://127.0.0.1:5001":true,"not a node":true}}`
+	batch := store.NewBatch()
+	batch.Put([]byte(constants.BLOCKCHAIN_KEY), []byte(blob))
+	err = store.Write(batch)
+	if err != nil {
+		t.Fatal(err)
+	}
+	store.Close()
+
+	store, err = OpenLevelDbStore(path)
+	if err != nil {
+		t.Fatalf("OpenLevelDbStore() error = %v", err)
+	}
+	defer store.Close()
+
+	has, err := store.Has([]byte(constants.BLOCKCHAIN_KEY))
+	if err != nil || has {
+		t.Fatalf("the legacy blob was kept: %v", err)
+	}
+	data, err := store.Get(legacyBlockKey(1))
+	if err != nil || string(data) != `{"blockNumber":1,"prevHash":"0x00000abc"}` {
+		t.Fatalf("legacy block 1 = %s, %v", data, err)
+	}
+	peers, _, err := GetAddressBookFromDb(store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(peers) != 2 {
+		t.Fatalf("migrated %d peers, want 2", len(peers))
+	}
+
+	// the chain itself is initialized again, then synced from the peers
+	exists, err := HasBlockchain(store)
+	if err != nil || exists {
+		t.Fatalf("HasBlockchain() = %v, %v after the migration", exists, err)
+	}
+	cfg := testConfig(t)
+	err = InitBlockchain(store, testGenesis(cfg, nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	bc, err := NewBlockchain(store, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(bc.Peers.Peers()) != 1 {
+		t.Fatalf("the address book holds %d peers, want the one node address", len(bc.Peers.Peers()))
+	}
+}
@@ -0,0 +1,171 @@
+package blockchain
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"time"
+
+	"github.com/sap200/evochain/config"
+	"github.com/sap200/evochain/constants"
+)
+
+// ChainParams are the consensus parameters every node of a network must
+// agree on. They come from the genesis of the chain.
+type ChainParams struct {
+	ChainId         uint64 `json:"chain_id"`
+	TargetBlockTime uint64 `json:"target_block_time"` // In seconds
+	RetargetWindow  uint64 `json:"retarget_window"`   // In blocks
+	InitialReward   uint64 `json:"initial_reward"`
+	HalvingInterval uint64 `json:"halving_interval"` // In blocks, zero never halves
+	TailReward      uint64 `json:"tail_reward"`      // Reward never goes below
+	MaxSupply       uint64 `json:"max_supply"`       // Zero for no cap
+}
+
+func NewChainParams(cfg *config.Config) *ChainParams {
+	params := new(ChainParams)
+	params.ChainId = cfg.ChainId
+	params.TargetBlockTime = cfg.TargetBlockTime
+	params.RetargetWindow = cfg.RetargetWindow
+	params.InitialReward = cfg.InitialReward
+	params.HalvingInterval = cfg.HalvingInterval
+	params.TailReward = cfg.TailReward
+	params.MaxSupply = cfg.MaxSupply
+	return params
+}
+
+// Validate checks every parameter, so neither NextTarget nor the emission
+// schedule can fail on them. It is called once whenever parameters are
+// loaded, from the config or a genesis.
+func (params *ChainParams) Validate() error {
+	// transactions signed without a chain id would be valid on every network
+	if params.ChainId == 0 {
+		return errors.New("chain_id must be greater than zero")
+	}
+	if params.TargetBlockTime == 0 {
+		return errors.New("target_block_time must be greater than zero")
+	}
+	// the longest a window may be stretched to must fit in nanoseconds
+	if params.RetargetWindow > math.MaxInt64/uint64(time.Second)/constants.MAX_RETARGET_FACTOR/params.TargetBlockTime {
+		return fmt.Errorf("retarget_window of %d blocks of %d seconds is too long", params.RetargetWindow, params.TargetBlockTime)
+	}
+	if params.InitialReward == 0 {
+		return errors.New("initial_reward must be greater than zero")
+	}
+	if params.TailReward > params.InitialReward {
+		return fmt.Errorf("tail_reward %d is above initial_reward %d", params.TailReward, params.InitialReward)
+	}
+	// the end of a halving era must fit in a block number
+	if params.HalvingInterval > math.MaxUint64/2 {
+		return fmt.Errorf("halving_interval of %d blocks is too long", params.HalvingInterval)
+	}
+	return nil
+}
+
+// TargetToBig parses a target written as 0x followed by 64 hex characters.
+func TargetToBig(target string) (*big.Int, error) {
+	if len(target) != 2+64 || target[:2] != constants.HEX_PREFIX {
+		return nil, fmt.Errorf("malformed target %q", target)
+	}
+
+	n, ok := new(big.Int).SetString(target[2:], 16)
+	if !ok {
+		return nil, fmt.Errorf("malformed target %q", target)
+	}
+	return n, nil
+}
+
+func BigToTarget(n *big.Int) string {
+	return fmt.Sprintf("%s%064x", constants.HEX_PREFIX, n)
+}
+
+// mustTargetToBig is for the targets in constants only.
+func mustTargetToBig(target string) *big.Int {
+	n, err := TargetToBig(target)
+	if err != nil {
+		panic(err.Error())
+	}
+	return n
+}
+
+// powLimit is POW_LIMIT as a number. Never modify it.
+var powLimit = mustTargetToBig(constants.POW_LIMIT)
+
+// checkProofOfWork reports whether the block hash, read as a 256-bit
+// number, is at most the block target.
+func checkProofOfWork(h BlockHeader) bool {
+	target, err := TargetToBig(h.Target)
+	if err != nil {
+		return false
+	}
+
+	if target.Sign() <= 0 || target.Cmp(powLimit) > 0 {
+		return false
+	}
+
+	hash, ok := new(big.Int).SetString(h.Hash()[2:], 16)
+	if !ok {
+		return false
+	}
+
+	return hash.Cmp(target) <= 0
+}
+
+// NextTarget returns the target the block after the last header of chain
+// must carry. chain must hold every header up to the parent, or at least the
+// last RetargetWindow+1 of them.
+//
+// The parent target is scaled by how long the last RetargetWindow blocks
+// actually took compared to TargetBlockTime each, by at most a factor of
+// MAX_RETARGET_FACTOR either way and never above POW_LIMIT.
+//
+// params must have passed Validate. A parent target that is not a valid
+// target is an error.
+func NextTarget(params *ChainParams, chain []BlockHeader) (string, error) {
+	parent := chain[len(chain)-1]
+	target, err := TargetToBig(parent.Target)
+	if err != nil {
+		return "", fmt.Errorf("block %d: %s", parent.BlockNumber, err.Error())
+	}
+
+	window := params.RetargetWindow
+	if window == 0 || parent.BlockNumber < window || uint64(len(chain)) <= window {
+		return parent.Target, nil
+	}
+
+	first := chain[uint64(len(chain))-1-window]
+	actual := parent.Timestamp - first.Timestamp
+	expected := int64(window*params.TargetBlockTime) * 1e9
+
+	if actual < expected/constants.MAX_RETARGET_FACTOR {
+		actual = expected / constants.MAX_RETARGET_FACTOR
+	}
+	if actual > expected*constants.MAX_RETARGET_FACTOR {
+		actual = expected * constants.MAX_RETARGET_FACTOR
+	}
+
+	target.Mul(target, big.NewInt(actual))
+	target.Div(target, big.NewInt(expected))
+
+	if target.Cmp(powLimit) > 0 {
+		target.Set(powLimit)
+	}
+	if target.Sign() == 0 {
+		target.SetInt64(1)
+	}
+
+	return BigToTarget(target), nil
+}
+
+// BlockWork is the expected number of hashes needed to meet the block
+// target, 2^256 / (target + 1).
+func BlockWork(b *Block) *big.Int {
+	target, err := TargetToBig(b.Target)
+	if err != nil {
+		return big.NewInt(0)
+	}
+
+	numerator := new(big.Int).Lsh(big.NewInt(1), 256)
+	return numerator.Div(numerator, target.Add(target, big.NewInt(1)))
+}
@@ -0,0 +1,91 @@
+package blockchain
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/sap200/evochain/config"
+	"github.com/sap200/evochain/constants"
+)
+
+func TestChainParamsValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		change  func(params *ChainParams)
+		wantErr bool
+	}{
+		{"default", func(params *ChainParams) {}, false},
+		{"no retarget", func(params *ChainParams) { params.RetargetWindow = 0 }, false},
+		{"no halving", func(params *ChainParams) { params.HalvingInterval = 0 }, false},
+		{"zero chain id", func(params *ChainParams) { params.ChainId = 0 }, true},
+		{"zero block time", func(params *ChainParams) { params.TargetBlockTime = 0 }, true},
+		{"window overflowing", func(params *ChainParams) {
+			params.TargetBlockTime = 1 << 20
+			params.RetargetWindow = 1 << 20
+		}, true},
+		{"zero reward", func(params *ChainParams) { params.InitialReward = 0 }, true},
+		{"tail above initial reward", func(params *ChainParams) { params.TailReward = params.InitialReward + 1 }, true},
+		{"halving interval overflowing", func(params *ChainParams) { params.HalvingInterval = 1 << 63 }, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			params := NewChainParams(config.Default())
+			tt.change(params)
+			err := params.Validate()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Validate() error = %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// chainOf returns count headers at target, spaced by interval seconds.
+func chainOf(count int, target string, interval int64) []BlockHeader {
+	chain := []BlockHeader{}
+	for i := 0; i < count; i++ {
+		b := NewBlock("", 0, uint64(i))
+		b.Timestamp = int64(i) * interval * 1e9
+		b.Target = target
+		chain = append(chain, b.BlockHeader)
+	}
+	return chain
+}
+
+func TestNextTarget(t *testing.T) {
+	params := &ChainParams{TargetBlockTime: 10, RetargetWindow: 4}
+	initial, _ := TargetToBig(constants.INITIAL_TARGET)
+
+	tests := []struct {
+		name     string
+		parent   string
+		interval int64
+		want     *big.Int
+	}{
+		{"on time", constants.INITIAL_TARGET, 10, initial},
+		{"twice as slow", constants.INITIAL_TARGET, 20, new(big.Int).Mul(initial, big.NewInt(2))},
+		{"much faster", constants.INITIAL_TARGET, 0, new(big.Int).Div(initial, big.NewInt(constants.MAX_RETARGET_FACTOR))},
+		{"much slower", constants.INITIAL_TARGET, 1000, new(big.Int).Mul(initial, big.NewInt(constants.MAX_RETARGET_FACTOR))},
+		{"slower at the limit", constants.POW_LIMIT, 20, powLimit},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			target, err := NextTarget(params, chainOf(5, tt.parent, tt.interval))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if target != BigToTarget(tt.want) {
+				t.Fatalf("NextTarget() = %s, want %s", target, BigToTarget(tt.want))
+			}
+		})
+	}
+}
+
+func TestNextTargetRejectsMalformedParent(t *testing.T) {
+	params := &ChainParams{TargetBlockTime: 10, RetargetWindow: 4}
+	for _, target := range []string{"", "0x01", constants.INITIAL_TARGET[:65] + "z"} {
+		_, err := NextTarget(params, chainOf(5, target, 10))
+		if err == nil {
+			t.Fatalf("NextTarget() accepted parent target %q", target)
+		}
+	}
+}
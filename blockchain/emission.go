@@ -0,0 +1,147 @@
+package blockchain
+
+import (
+	"math/big"
+)
+
+type Supply struct {
+	Height            uint64 `json:"height"`
+	CurrentReward     uint64 `json:"current_reward"` // Reward of the next block
+	NextHalving       uint64 `json:"next_halving,omitempty"`
+	Allocated         uint64 `json:"allocated"`    // By the genesis
+	TotalMinted       uint64 `json:"total_minted"` // By block rewards
+	CirculatingSupply uint64 `json:"circulating_supply"`
+	MaxSupply         uint64 `json:"max_supply,omitempty"`
+}
+
+// scheduledReward is the reward of block height before the supply cap: the
+// initial reward halved every halving interval, never below the tail reward.
+// The genesis block mints nothing.
+func (params *ChainParams) scheduledReward(height uint64) uint64 {
+	if height == 0 {
+		return 0
+	}
+
+	reward := params.InitialReward
+	if params.HalvingInterval > 0 {
+		halvings := (height - 1) / params.HalvingInterval
+		if halvings >= 64 {
+			reward = 0
+		} else {
+			reward >>= halvings
+		}
+	}
+
+	if reward < params.TailReward {
+		reward = params.TailReward
+	}
+	return reward
+}
+
+// scheduledMinted sums the scheduled rewards of the blocks up to height, era
+// by era rather than block by block.
+func (params *ChainParams) scheduledMinted(height uint64) *big.Int {
+	minted := big.NewInt(0)
+	for first := uint64(1); first <= height; {
+		last := height
+		if params.HalvingInterval > 0 {
+			eraEnd := first + params.HalvingInterval - 1 - (first-1)%params.HalvingInterval
+			if eraEnd < last {
+				last = eraEnd
+			}
+		}
+
+		reward := params.scheduledReward(first)
+		era := new(big.Int).SetUint64(last - first + 1)
+		minted.Add(minted, era.Mul(era, new(big.Int).SetUint64(reward)))
+
+		// past the last halving every block pays the same
+		if reward == params.TailReward || params.HalvingInterval == 0 {
+			rest := new(big.Int).SetUint64(height - last)
+			minted.Add(minted, rest.Mul(rest, new(big.Int).SetUint64(reward)))
+			break
+		}
+		first = last + 1
+	}
+	return minted
+}
+
+// TotalMinted is the number of coins created by the blocks up to height.
+func (params *ChainParams) TotalMinted(height uint64) uint64 {
+	minted := params.scheduledMinted(height)
+	if params.MaxSupply > 0 && minted.Cmp(new(big.Int).SetUint64(params.MaxSupply)) > 0 {
+		return params.MaxSupply
+	}
+	if !minted.IsUint64() {
+		return ^uint64(0)
+	}
+	return minted.Uint64()
+}
+
+// BlockReward is what the coinbase of block height may mint: the scheduled
+// reward, cut down to what is left under the supply cap.
+func (params *ChainParams) BlockReward(height uint64) uint64 {
+	if height == 0 {
+		return 0
+	}
+
+	reward := params.scheduledReward(height)
+	if params.MaxSupply > 0 {
+		left := params.MaxSupply - params.TotalMinted(height-1)
+		if reward > left {
+			reward = left
+		}
+	}
+	return reward
+}
+
+// NextHalving is the first block after height whose scheduled reward is
+// halved, zero once the reward no longer changes.
+func (params *ChainParams) NextHalving(height uint64) uint64 {
+	if params.HalvingInterval == 0 {
+		return 0
+	}
+	if params.MaxSupply > 0 && params.TotalMinted(height) >= params.MaxSupply {
+		return 0
+	}
+
+	next := height/params.HalvingInterval*params.HalvingInterval + 1
+	if next <= height || next == 1 {
+		next += params.HalvingInterval
+	}
+	if params.scheduledReward(next) == params.scheduledReward(next-1) {
+		return 0
+	}
+	return next
+}
+
+// GetSupply reports the emission at our tip. The circulating supply is the
+// sum of every balance, which the allocated and minted coins end up in.
+func (bc *BlockchainStruct) GetSupply() (*Supply, error) {
+	bc.mu.RLock()
+	defer bc.mu.RUnlock()
+
+	height := bc.tip.BlockNumber
+
+	supply := new(Supply)
+	supply.Height = height
+	supply.CurrentReward = bc.Params.BlockReward(height + 1)
+	supply.NextHalving = bc.Params.NextHalving(height)
+	supply.TotalMinted = bc.Params.TotalMinted(height)
+	supply.MaxSupply = bc.Params.MaxSupply
+	genesis, err := bc.Store.GetBlockByNumber(0)
+	if err != nil {
+		return nil, err
+	}
+	for _, txn := range genesis.Transactions {
+		supply.Allocated += txn.Value
+	}
+
+	circulating, err := SumBalancesFromDb(bc.Store)
+	if err != nil {
+		return nil, err
+	}
+	supply.CirculatingSupply = circulating
+
+	return supply, nil
+}
@@ -0,0 +1,89 @@
+package blockchain
+
+import (
+	"math"
+	"testing"
+)
+
+func TestScheduledMintedSumsEveryReward(t *testing.T) {
+	tests := []struct {
+		name   string
+		params ChainParams
+	}{
+		{"flat reward", ChainParams{InitialReward: 50}},
+		{"halving to zero", ChainParams{InitialReward: 100, HalvingInterval: 3}},
+		{"halving to a tail", ChainParams{InitialReward: 100, HalvingInterval: 4, TailReward: 10}},
+		{"tail above the initial reward", ChainParams{InitialReward: 5, HalvingInterval: 2, TailReward: 8}},
+		{"interval of one", ChainParams{InitialReward: 1 << 10, HalvingInterval: 1, TailReward: 1}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sum := uint64(0)
+			for height := uint64(0); height <= 100; height++ {
+				sum += tt.params.scheduledReward(height)
+				if minted := tt.params.scheduledMinted(height); !minted.IsUint64() || minted.Uint64() != sum {
+					t.Fatalf("scheduledMinted(%d) = %s, want %d", height, minted, sum)
+				}
+			}
+		})
+	}
+}
+
+func TestScheduledMintedOfALongChain(t *testing.T) {
+	params := ChainParams{InitialReward: math.MaxUint64, HalvingInterval: 1000}
+	minted := params.scheduledMinted(math.MaxUint64)
+	if minted.IsUint64() {
+		t.Fatalf("scheduledMinted() = %s, want more than fits a uint64", minted)
+	}
+	if total := params.TotalMinted(math.MaxUint64); total != math.MaxUint64 {
+		t.Fatalf("TotalMinted() = %d, want it saturated", total)
+	}
+}
+
+func TestBlockRewardStopsAtMaxSupply(t *testing.T) {
+	params := ChainParams{InitialReward: 100, HalvingInterval: 2, TailReward: 30, MaxSupply: 425}
+
+	want := []uint64{0, 100, 100, 50, 50, 30, 30, 30, 30, 5, 0, 0}
+	minted := uint64(0)
+	for height, reward := range want {
+		if got := params.BlockReward(uint64(height)); got != reward {
+			t.Fatalf("BlockReward(%d) = %d, want %d", height, got, reward)
+		}
+		minted += reward
+		if got := params.TotalMinted(uint64(height)); got != minted {
+			t.Fatalf("TotalMinted(%d) = %d, want %d", height, got, minted)
+		}
+	}
+	if minted != params.MaxSupply {
+		t.Fatalf("minted %d, want the max supply %d", minted, params.MaxSupply)
+	}
+}
+
+func TestNextHalving(t *testing.T) {
+	tests := []struct {
+		name   string
+		params ChainParams
+		height uint64
+		want   uint64
+	}{
+		{"genesis", ChainParams{InitialReward: 100, HalvingInterval: 10}, 0, 11},
+		{"first era", ChainParams{InitialReward: 100, HalvingInterval: 10}, 5, 11},
+		{"last block of an era", ChainParams{InitialReward: 100, HalvingInterval: 10}, 10, 11},
+		{"first block of an era", ChainParams{InitialReward: 100, HalvingInterval: 10}, 11, 21},
+		{"never halving", ChainParams{InitialReward: 100}, 5, 0},
+		{"reward at the tail", ChainParams{InitialReward: 100, HalvingInterval: 10, TailReward: 25}, 25, 0},
+		{"before the halving to the tail", ChainParams{InitialReward: 100, HalvingInterval: 10, TailReward: 25}, 15, 21},
+		{"last block before the tail", ChainParams{InitialReward: 100, HalvingInterval: 10, TailReward: 25}, 20, 21},
+		{"halving to nothing", ChainParams{InitialReward: 1, HalvingInterval: 10}, 10, 11},
+		{"reward run out", ChainParams{InitialReward: 1, HalvingInterval: 10}, 11, 0},
+		{"supply cap reached", ChainParams{InitialReward: 100, HalvingInterval: 10, MaxSupply: 300}, 3, 0},
+		{"supply cap ahead", ChainParams{InitialReward: 100, HalvingInterval: 10, MaxSupply: 300}, 2, 11},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.params.NextHalving(tt.height); got != tt.want {
+				t.Fatalf("NextHalving(%d) = %d, want %d", tt.height, got, tt.want)
+			}
+		})
+	}
+}
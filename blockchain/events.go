@@ -0,0 +1,75 @@
+package blockchain
+
+import (
+	"sync"
+	"time"
+
+	"github.com/sap200/evochain/constants"
+)
+
+type ReorgEvent struct {
+	CommonAncestor uint64   `json:"common_ancestor"`
+	OldTip         string   `json:"old_tip"`
+	NewTip         string   `json:"new_tip"`
+	Removed        []string `json:"removed"`
+	Added          []string `json:"added"`
+	Orphaned       []string `json:"orphaned_transactions"`
+}
+
+type ChainEvent struct {
+	Type        string      `json:"type"`
+	BlockHash   string      `json:"block_hash"`
+	BlockNumber uint64      `json:"block_number"`
+	Timestamp   int64       `json:"timestamp"`
+	Reorg       *ReorgEvent `json:"reorg,omitempty"`
+}
+
+// EventFeed fans chain events out to subscribers and keeps the most recent
+// ones around for the events endpoint. A subscriber that does not keep up
+// misses events rather than blocking the chain.
+type EventFeed struct {
+	mu     sync.Mutex
+	subs   []chan ChainEvent
+	recent []ChainEvent
+}
+
+func NewEventFeed() *EventFeed {
+	feed := new(EventFeed)
+	feed.subs = []chan ChainEvent{}
+	feed.recent = []ChainEvent{}
+	return feed
+}
+
+func (f *EventFeed) Subscribe() <-chan ChainEvent {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	ch := make(chan ChainEvent, constants.EVENT_SUBSCRIBER_BUFFER)
+	f.subs = append(f.subs, ch)
+	return ch
+}
+
+func (f *EventFeed) Publish(event ChainEvent) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	event.Timestamp = time.Now().UnixNano()
+	f.recent = append(f.recent, event)
+	if len(f.recent) > constants.RECENT_EVENTS {
+		f.recent = f.recent[len(f.recent)-constants.RECENT_EVENTS:]
+	}
+
+	for _, ch := range f.subs {
+		select {
+		case ch <- event:
+		default:
+		}
+	}
+}
+
+func (f *EventFeed) Recent() []ChainEvent {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return append([]ChainEvent{}, f.recent...)
+}
@@ -0,0 +1,159 @@
+package blockchain
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"sort"
+
+	"github.com/sap200/evochain/address"
+	"github.com/sap200/evochain/config"
+	"github.com/sap200/evochain/constants"
+)
+
+// Genesis describes the first block of a network: its consensus parameters,
+// its time and difficulty, and the balances it starts with. Every node built
+// from the same genesis gets the same genesis block, whose hash tells
+// networks apart.
+type Genesis struct {
+	Version uint32 `json:"version"` // Of the genesis block header
+	ChainParams
+	Timestamp int64             `json:"timestamp"` // In nanoseconds
+	Target    string            `json:"target"`
+	Alloc     map[string]uint64 `json:"alloc"` // Balance by address
+}
+
+// DefaultGenesis is the genesis of a network set up from the node config,
+// with no allocations.
+func DefaultGenesis(cfg *config.Config) *Genesis {
+	g := new(Genesis)
+	g.Version = constants.BLOCK_VERSION
+	g.ChainParams = *NewChainParams(cfg)
+	g.Timestamp = constants.GENESIS_TIMESTAMP
+	g.Target = constants.INITIAL_TARGET
+	g.Alloc = map[string]uint64{}
+	return g
+}
+
+// LoadGenesis reads a genesis file. Keys missing from the file keep the
+// value of the default genesis.
+func LoadGenesis(path string, cfg *config.Config) (*Genesis, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	g := DefaultGenesis(cfg)
+	err = json.Unmarshal(data, g)
+	if err != nil {
+		return nil, err
+	}
+	if g.Alloc == nil {
+		g.Alloc = map[string]uint64{}
+	}
+
+	return g, g.Validate()
+}
+
+// Validate checks the genesis and its chain parameters. Every genesis, read
+// from a file or fetched from a peer, is validated before it is used.
+func (g *Genesis) Validate() error {
+	if g.Version != constants.BLOCK_VERSION {
+		return fmt.Errorf("genesis has unsupported version %d", g.Version)
+	}
+
+	err := g.ChainParams.Validate()
+	if err != nil {
+		return err
+	}
+
+	target, err := TargetToBig(g.Target)
+	if err != nil {
+		return err
+	}
+	if target.Sign() <= 0 || target.Cmp(powLimit) > 0 {
+		return fmt.Errorf("genesis target %s is not between 1 and the proof of work limit", g.Target)
+	}
+
+	total := uint64(0)
+	for owner, balance := range g.Alloc {
+		if !address.IsValid(owner) {
+			return fmt.Errorf("cannot allocate funds to %q, which is not an address", owner)
+		}
+		if balance == 0 {
+			return fmt.Errorf("allocation to %s must be greater than zero", owner)
+		}
+		if balance > math.MaxUint64-total {
+			return errors.New("genesis allocations overflow")
+		}
+		total += balance
+	}
+	if g.MaxSupply > math.MaxUint64-total {
+		return errors.New("genesis allocations and max_supply overflow")
+	}
+
+	return nil
+}
+
+// Hash commits to every field of the genesis.
+func (g *Genesis) Hash() string {
+	bs, _ := json.Marshal(g)
+	sum := sha256.Sum256(bs)
+	return constants.HEX_PREFIX + hex.EncodeToString(sum[:])
+}
+
+// Block builds the genesis block. It has no parent, so it links to the hash
+// of the genesis instead, and it credits the allocations in address order.
+func (g *Genesis) Block() *Block {
+	addresses := make([]string, 0, len(g.Alloc))
+	for address := range g.Alloc {
+		addresses = append(addresses, address)
+	}
+	sort.Strings(addresses)
+
+	block := new(Block)
+	block.Version = g.Version
+	block.PrevHash = g.Hash()
+	block.Timestamp = g.Timestamp
+	block.Nonce = 0
+	block.BlockNumber = 0
+	block.Target = g.Target
+	block.Transactions = []*Transaction{}
+	for _, address := range addresses {
+		txn := &Transaction{
+			From:      constants.BLOCKCHAIN_ADDRESS,
+			To:        address,
+			Value:     g.Alloc[address],
+			Data:      []byte{},
+			Timestamp: g.Timestamp,
+			Signature: []byte{},
+		}
+		txn.TransactionHash = txn.Hash()
+		txn.Status = constants.SUCCESS
+		block.Transactions = append(block.Transactions, txn)
+	}
+	block.MerkleRoot = block.ComputeMerkleRoot()
+
+	return block
+}
+
+// GetGenesisFromDb returns the genesis the chain was initialized from.
+func GetGenesisFromDb(store Store) (*Genesis, error) {
+	data, err := store.Get([]byte(constants.GENESIS_KEY))
+	if err != nil {
+		return nil, err
+	}
+
+	g := new(Genesis)
+	err = json.Unmarshal(data, g)
+	return g, err
+}
+
+// GenesisHash identifies the network we are on.
+func (bc *BlockchainStruct) GenesisHash() string {
+	return bc.genesisHash
+}
@@ -0,0 +1,97 @@
+package blockchain
+
+import (
+	"encoding/json"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/sap200/evochain/constants"
+)
+
+func TestGenesisValidate(t *testing.T) {
+	owner := newTestKey(t).address
+	tests := []struct {
+		name    string
+		change  func(g *Genesis)
+		wantErr bool
+	}{
+		{"default", func(g *Genesis) {}, false},
+		{"allocation", func(g *Genesis) { g.Alloc[owner] = 10 }, false},
+		{"unsupported version", func(g *Genesis) { g.Version = constants.BLOCK_VERSION + 1 }, true},
+		{"legacy version", func(g *Genesis) { g.Version = 0 }, true},
+		{"invalid params", func(g *Genesis) { g.TargetBlockTime = 0 }, true},
+		{"malformed target", func(g *Genesis) { g.Target = "0x01" }, true},
+		{"target above the limit", func(g *Genesis) { g.Target = "0x" + "1" + constants.POW_LIMIT[3:] }, true},
+		{"zero allocation", func(g *Genesis) { g.Alloc[owner] = 0 }, true},
+		{"allocation to the chain", func(g *Genesis) { g.Alloc[constants.BLOCKCHAIN_ADDRESS] = 10 }, true},
+		{"allocation to a malformed address", func(g *Genesis) { g.Alloc["evochainaaaa"] = 10 }, true},
+		{"supply overflowing", func(g *Genesis) {
+			g.Alloc[owner] = math.MaxUint64 / 2
+			g.MaxSupply = math.MaxUint64/2 + 2
+		}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := DefaultGenesis(testConfig(t))
+			tt.change(g)
+			err := g.Validate()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Validate() error = %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestFetchGenesisValidates(t *testing.T) {
+	g := DefaultGenesis(testConfig(t))
+	g.TargetBlockTime = 0
+
+	peer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		switch req.URL.Path {
+		case "/genesis":
+			json.NewEncoder(w).Encode(g)
+		case "/tip":
+			json.NewEncoder(w).Encode(&ChainTip{GenesisHash: g.Block().Hash()})
+		default:
+			http.NotFound(w, req)
+		}
+	}))
+	defer peer.Close()
+
+	_, err := FetchGenesis(peer.URL)
+	if err == nil {
+		t.Fatal("FetchGenesis() accepted a genesis with a zero target block time")
+	}
+}
+
+func TestNewBlockchainTakesTheParamsOfTheGenesis(t *testing.T) {
+	cfg := testConfig(t)
+	store := NewMemoryStore()
+	err := InitBlockchain(store, testGenesis(cfg, nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	changed := testConfig(t)
+	changed.TargetBlockTime = cfg.TargetBlockTime * 2
+	bc, err := NewBlockchain(store, changed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bc.Params.TargetBlockTime != cfg.TargetBlockTime {
+		t.Fatalf("TargetBlockTime = %d, want %d of the genesis", bc.Params.TargetBlockTime, cfg.TargetBlockTime)
+	}
+
+	batch := store.NewBatch()
+	batch.Delete([]byte(constants.GENESIS_KEY))
+	err = store.Write(batch)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = NewBlockchain(store, cfg)
+	if err == nil {
+		t.Fatal("NewBlockchain() took the chain parameters of the config without a genesis")
+	}
+}
@@ -0,0 +1,141 @@
+package blockchain
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"testing"
+
+	"github.com/sap200/evochain/address"
+	"github.com/sap200/evochain/config"
+	"github.com/sap200/evochain/constants"
+)
+
+// testGenesis is an easy network for tests: every block at the proof of
+// work limit, so a block takes a few tens of thousands of hashes.
+func testGenesis(cfg *config.Config, alloc map[string]uint64) *Genesis {
+	g := DefaultGenesis(cfg)
+	g.Target = constants.POW_LIMIT
+	g.RetargetWindow = 0
+	for address, balance := range alloc {
+		g.Alloc[address] = balance
+	}
+	return g
+}
+
+func testConfig(t *testing.T) *config.Config {
+	cfg := config.Default()
+	cfg.DataDir = t.TempDir()
+	return cfg
+}
+
+// newTestChain returns a node on an in-memory store initialized from the
+// test genesis with alloc.
+func newTestChain(t *testing.T, alloc map[string]uint64) *BlockchainStruct {
+	t.Helper()
+
+	cfg := testConfig(t)
+	store := NewMemoryStore()
+	err := InitBlockchain(store, testGenesis(cfg, alloc))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	bc, err := NewBlockchain(store, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return bc
+}
+
+type testKey struct {
+	key     *ecdsa.PrivateKey
+	address string
+}
+
+func newTestKey(t *testing.T) *testKey {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &testKey{key, address.FromPublicKey(&key.PublicKey)}
+}
+
+// transfer returns a transaction from k signed for the chain of params.
+func (k *testKey) transfer(t *testing.T, params *ChainParams, to string, value uint64, fee uint64, nonce uint64) *Transaction {
+	t.Helper()
+
+	txn := NewTransaction(k.address, to, value, fee, nonce, []byte{})
+	txn.ChainId = params.ChainId
+	txn.TransactionHash = txn.Hash()
+	hash := txn.SigningHash()
+	sig, err := ecdsa.SignASN1(rand.Reader, k.key, hash[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	txn.Signature = sig
+	txn.PublicKey = address.PublicKeyHex(&k.key.PublicKey)
+	return txn
+}
+
+// canonicalChain reads every block of the chain of bc from its store.
+func canonicalChain(t *testing.T, bc *BlockchainStruct) []*Block {
+	t.Helper()
+
+	blocks := []*Block{}
+	err := bc.Store.IterateBlocks(0, func(b *Block) bool {
+		blocks = append(blocks, b)
+		return true
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return blocks
+}
+
+func headersOf(blocks []*Block) []BlockHeader {
+	headers := []BlockHeader{}
+	for _, b := range blocks {
+		headers = append(headers, b.BlockHeader)
+	}
+	return headers
+}
+
+// nextBlock builds the block after parent holding txns and a coinbase paying
+// the reward and their fees to miner, with no proof of work yet.
+func nextBlock(params *ChainParams, chain []*Block, miner string, txns ...*Transaction) *Block {
+	parent := chain[len(chain)-1]
+	b := NewBlock(parent.Hash(), 0, parent.BlockNumber+1)
+	b.Timestamp = parent.Timestamp + int64(params.TargetBlockTime)*1e9
+	b.Target, _ = NextTarget(params, headersOf(chain))
+
+	fees := uint64(0)
+	for _, txn := range txns {
+		block := *txn
+		block.Status = constants.SUCCESS
+		b.Transactions = append(b.Transactions, &block)
+		fees += txn.Fee
+	}
+	coinbase := NewTransaction(constants.BLOCKCHAIN_ADDRESS, miner, params.BlockReward(b.BlockNumber)+fees, 0, 0, []byte{})
+	coinbase.Timestamp = b.Timestamp
+	coinbase.TransactionHash = coinbase.Hash()
+	coinbase.Status = constants.SUCCESS
+	b.Transactions = append(b.Transactions, coinbase)
+	b.MerkleRoot = b.ComputeMerkleRoot()
+	return b
+}
+
+// solve finds a nonce meeting the target of b.
+func solve(b *Block) *Block {
+	for !checkProofOfWork(b.BlockHeader) {
+		b.Nonce++
+	}
+	return b
+}
+
+// mineOn returns the solved block after the last block of chain.
+func mineOn(params *ChainParams, chain []*Block, miner string, txns ...*Transaction) *Block {
+	return solve(nextBlock(params, chain, miner, txns...))
+}
@@ -0,0 +1,303 @@
+package blockchain
+
+import (
+	"errors"
+	"log"
+	"math"
+	"math/big"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/sap200/evochain/constants"
+)
+
+// MinerTemplate is the block the miner is working on, less its nonce.
+type MinerTemplate struct {
+	BlockNumber  uint64 `json:"block_number"`
+	PrevHash     string `json:"prev_hash"`
+	Target       string `json:"target"`
+	Transactions int    `json:"transactions"` // Including the coinbase
+	Reward       uint64 `json:"reward"`       // Block reward and fees
+	CreatedAt    int64  `json:"created_at"`   // In nanoseconds
+}
+
+type MinerStatus struct {
+	Running       bool           `json:"running"`
+	MinersAddress string         `json:"miners_address"`
+	Workers       int            `json:"workers"`
+	Hashrate      uint64         `json:"hashrate"` // Hashes per second
+	Hashes        uint64         `json:"hashes"`   // Since the node started
+	BlocksFound   uint64         `json:"blocks_found"`
+	Template      *MinerTemplate `json:"template,omitempty"`
+}
+
+var (
+	ErrMinerRunning    = errors.New("the miner is already running")
+	ErrMinerStopped    = errors.New("the miner is not running")
+	ErrNoMinersAddress = errors.New("no miners address to credit the mining reward to")
+)
+
+// Miner mines blocks on top of our tip. It builds a template once per tip or
+// pool change and splits the nonces of the template among its workers, which
+// give up on it as soon as our tip moves or the pool changes.
+type Miner struct {
+	bc          *BlockchainStruct
+	tips        <-chan ChainEvent
+	hashes      atomic.Uint64
+	blocksFound atomic.Uint64
+
+	// control serializes Start and Stop, mu guards the fields below
+	control  sync.Mutex
+	mu       sync.Mutex
+	running  bool
+	address  string
+	workers  int
+	quit     chan struct{}
+	done     chan struct{}
+	template *MinerTemplate
+	hashrate uint64
+}
+
+// NewMiner returns a stopped miner crediting minersAddress with workers
+// workers, one per CPU when workers is zero.
+func NewMiner(bc *BlockchainStruct, minersAddress string, workers int) *Miner {
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+	}
+
+	m := new(Miner)
+	m.bc = bc
+	m.tips = bc.Events.Subscribe()
+	m.address = minersAddress
+	m.workers = workers
+	return m
+}
+
+// Start starts mining for the miners address the miner was created with.
+// Zero workers keep the previous number.
+func (m *Miner) Start(workers int) error {
+	m.control.Lock()
+	defer m.control.Unlock()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.running {
+		return ErrMinerRunning
+	}
+	if m.address == "" {
+		return ErrNoMinersAddress
+	}
+	if workers > 0 {
+		m.workers = workers
+	}
+
+	m.running = true
+	m.quit = make(chan struct{})
+	m.done = make(chan struct{})
+	go m.run(m.address, m.workers, m.quit, m.done)
+
+	log.Println("Starting to Mine with", m.workers, "workers...")
+	return nil
+}
+
+// Stop stops mining and waits for the workers to return.
+func (m *Miner) Stop() error {
+	m.control.Lock()
+	defer m.control.Unlock()
+
+	m.mu.Lock()
+	if !m.running {
+		m.mu.Unlock()
+		return ErrMinerStopped
+	}
+	quit, done := m.quit, m.done
+	m.mu.Unlock()
+
+	close(quit)
+	<-done
+
+	m.mu.Lock()
+	m.running = false
+	m.template = nil
+	m.hashrate = 0
+	m.mu.Unlock()
+
+	log.Println("Stopped mining")
+	return nil
+}
+
+func (m *Miner) Status() *MinerStatus {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	status := new(MinerStatus)
+	status.Running = m.running
+	status.MinersAddress = m.address
+	status.Workers = m.workers
+	status.Hashrate = m.hashrate
+	status.Hashes = m.hashes.Load()
+	status.BlocksFound = m.blocksFound.Load()
+	status.Template = m.template
+	return status
+}
+
+// run mines one template after the other until quit is closed.
+func (m *Miner) run(minersAddress string, workers int, quit chan struct{}, done chan struct{}) {
+	defer close(done)
+
+	ticker := time.NewTicker(constants.MINER_POLL_INTERVAL * time.Millisecond)
+	defer ticker.Stop()
+	rateHashes := m.hashes.Load()
+	rateTime := time.Now()
+
+	found := make(chan BlockHeader, workers)
+	span := math.MaxInt / workers
+	for {
+		template, poolChanges, err := m.newTemplate(minersAddress)
+		if err != nil {
+			log.Println("Error while building a block template:", err.Error())
+			// try again on the next tip
+			select {
+			case <-quit:
+				return
+			case <-m.tips:
+			}
+			continue
+		}
+
+		abort := make(chan struct{})
+		var wg sync.WaitGroup
+		for i := 0; i < workers; i++ {
+			wg.Add(1)
+			go func(first int) {
+				defer wg.Done()
+				m.work(template.BlockHeader, first, first+span, abort, found)
+			}(i * span)
+		}
+
+		stopped := false
+		for stale := false; !stale && !stopped; {
+			select {
+			case <-quit:
+				stopped = true
+			case <-m.tips:
+				stale = true
+			case header := <-found:
+				block := new(Block)
+				block.BlockHeader = header
+				block.Transactions = template.Transactions
+				// our tip may have moved on before we got the chain lock
+				err := m.bc.AddBlock(block)
+				if err == nil {
+					m.blocksFound.Add(1)
+					log.Println("Mined block number:", block.BlockNumber)
+				}
+				stale = true
+			case now := <-ticker.C:
+				if elapsed := now.Sub(rateTime); elapsed >= time.Second {
+					hashes := m.hashes.Load()
+					m.mu.Lock()
+					m.hashrate = uint64(float64(hashes-rateHashes) / elapsed.Seconds())
+					m.mu.Unlock()
+					rateHashes = hashes
+					rateTime = now
+				}
+				if m.bc.Pool.Changes() != poolChanges {
+					stale = true
+				}
+			}
+		}
+
+		close(abort)
+		wg.Wait()
+		// proofs found for the old template are no use anymore
+		for drained := false; !drained; {
+			select {
+			case <-found:
+			default:
+				drained = true
+			}
+		}
+
+		if stopped {
+			return
+		}
+	}
+}
+
+// newTemplate builds the next block on our tip, with a zero nonce, and
+// returns it with the pool change count it was built at.
+func (m *Miner) newTemplate(minersAddress string) (*Block, uint64, error) {
+	// tips announced so far are all behind the one we build on
+	for drained := false; !drained; {
+		select {
+		case <-m.tips:
+		default:
+			drained = true
+		}
+	}
+
+	m.bc.mu.RLock()
+	poolChanges := m.bc.Pool.Changes()
+	block := NewBlock(m.bc.tip.Hash(), 0, m.bc.tip.BlockNumber+1)
+	block.Transactions = m.bc.blockTransactions(minersAddress)
+	block.MerkleRoot = block.ComputeMerkleRoot()
+	target, err := NextTarget(m.bc.Params, m.bc.headers)
+	m.bc.mu.RUnlock()
+	if err != nil {
+		return nil, 0, err
+	}
+	block.Target = target
+
+	template := new(MinerTemplate)
+	template.BlockNumber = block.BlockNumber
+	template.PrevHash = block.PrevHash
+	template.Target = block.Target
+	template.Transactions = len(block.Transactions)
+	template.Reward = block.Transactions[len(block.Transactions)-1].Value
+	template.CreatedAt = block.Timestamp
+
+	m.mu.Lock()
+	m.template = template
+	m.mu.Unlock()
+
+	return block, poolChanges, nil
+}
+
+// work tries the nonces of header from first up to last and sends the
+// header with the first one meeting the target to found. It checks for abort
+// between batches of nonces.
+func (m *Miner) work(header BlockHeader, first int, last int, abort chan struct{}, found chan BlockHeader) {
+	target, err := TargetToBig(header.Target)
+	if err != nil {
+		log.Println("Error while reading the target of the block template:", err.Error())
+		return
+	}
+
+	hash := new(big.Int)
+	batch := uint64(0)
+	for nonce := first; nonce < last; nonce++ {
+		header.Nonce = nonce
+		hash.SetString(header.Hash()[2:], 16)
+		batch++
+		if hash.Cmp(target) <= 0 {
+			m.hashes.Add(batch)
+			found <- header
+			return
+		}
+
+		if batch == constants.MINER_HASH_BATCH {
+			m.hashes.Add(batch)
+			batch = 0
+			select {
+			case <-abort:
+				return
+			default:
+			}
+		}
+	}
+	m.hashes.Add(batch)
+}
@@ -1,261 +1,200 @@
 package blockchain
 
 import (
-	"bytes"
-	"encoding/json"
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"log"
-	"net/http"
-	"strings"
+	"sort"
+	"sync"
 	"time"
 
+	"github.com/sap200/evochain/addrbook"
 	"github.com/sap200/evochain/constants"
 )
 
-func SyncBlockchain(address string) (*BlockchainStruct, error) {
-	log.Println("Started syncing blockchain from node:", address)
-	ourURL := fmt.Sprintf("%s/", address)
-	resp, err := http.Get(ourURL)
-	if err != nil {
-		return nil, err
+// UpdatePeers merges a peers list received from a peer into our address
+// book. Only the addresses are taken, what we know of them stays ours.
+func (bc *BlockchainStruct) UpdatePeers(peersList map[string]bool) {
+	addresses := []string{}
+	for peer := range peersList {
+		addresses = append(addresses, peer)
 	}
+	sort.Strings(addresses)
 
-	// read the body of the response here
-	data, err := ioutil.ReadAll(resp.Body)
-	if err != nil {
-		return nil, err
+	added := bc.Peers.Learn(addresses)
+	if added > 0 {
+		log.Println("Learned", added, "new peer addresses")
+		bc.savePeers()
 	}
-	defer resp.Body.Close()
+}
 
-	var bs BlockchainStruct
-	err = json.Unmarshal(data, &bs)
+func (bc *BlockchainStruct) savePeers() {
+	batch := bc.Store.NewBatch()
+	batch.SetAddressBook(bc.Peers.Peers(), bc.Peers.Hosts())
+	err := bc.Store.Write(batch)
 	if err != nil {
-		return nil, err
+		panic(err.Error())
 	}
+}
 
-	log.Println("Finished syncing blockchain from node:", address)
+// PeerContacted records that the node at address sent us something. It is
+// pinged to take an inbound slot if one is free.
+func (bc *BlockchainStruct) PeerContacted(address string) {
+	if !bc.Peers.Contacted(address) {
+		return
+	}
 
-	return &bs, nil
+	go bc.checkPeer(address)
 }
 
-func (bc *BlockchainStruct) UpdatePeers(peersList map[string]bool) {
-	mutex.Lock()
-	defer mutex.Unlock()
-
-	log.Println("Updating Peers List..", peersList)
-	bc.Peers = peersList
+// PeerMisbehaved adds points to the misbehavior score of host, the host we
+// fetched something invalid from or a request carrying it came from. The
+// host is banned once its score is high enough.
+func (bc *BlockchainStruct) PeerMisbehaved(host string, points int, reason string) {
+	log.Println("Host", host, "misbehaved:", reason)
+	if bc.Peers.Misbehaved(host, points) {
+		log.Println("Banning host:", host)
+		bc.savePeers()
+	}
+}
 
-	err := PutIntoDb(*bc)
-	if err != nil {
-		panic(err.Error())
+// checkPeer pings the node at address and records the outcome in our
+// address book.
+func (bc *BlockchainStruct) checkPeer(address string) {
+	if bc.CheckStatus(address) {
+		bc.Peers.Good(address)
+	} else {
+		bc.Peers.Failed(address)
 	}
 }
 
 func (bc *BlockchainStruct) SendPeersList(address string) {
-	data := bc.PeersToJson()
-	ourURL := fmt.Sprintf("%s/send_peers_list", address)
-	http.Post(ourURL, "application/json", bytes.NewBuffer(data))
+	bc.Gossip.Send(address, "/send_peers_list", bc.PeersToJson())
 }
 
+// CheckStatus reports whether the node at address is running on our network.
 func (bc *BlockchainStruct) CheckStatus(address string) bool {
 	ourURL := fmt.Sprintf("%s/check_status", address)
-	resp, err := http.Get(ourURL)
+	resp, err := syncClient.Get(ourURL)
 	if err != nil {
 		log.Println(err)
 		return false
 	}
+	defer resp.Body.Close()
 
 	data, err := ioutil.ReadAll(resp.Body)
 	if err != nil {
 		log.Println(err)
 		return false
 	}
-	defer resp.Body.Close()
 
-	return string(data) == constants.BLOCKCHAIN_STATUS
+	if string(data) != constants.BLOCKCHAIN_STATUS {
+		return false
+	}
+
+	tip, err := FetchTip(address)
+	if err != nil {
+		log.Println(err)
+		return false
+	}
+	if tip.GenesisHash != bc.GenesisHash() {
+		log.Println("Refusing peer on another network:", address, "Genesis:", tip.GenesisHash)
+		return false
+	}
+	return true
 }
 
 func (bc *BlockchainStruct) BroadcastPeerList() {
-	for peer, status := range bc.Peers {
-		if peer != bc.Address && status {
-			bc.SendPeersList(peer)
-			time.Sleep(constants.PEER_BROADCAST_PAUSE_TIME * time.Second)
-		}
+	for _, peer := range bc.activePeers() {
+		bc.SendPeersList(peer)
 	}
 }
 
+// DialAndUpdatePeers pings our active peers, and candidates for the free
+// outbound slots, in parallel, then saves the address book and sends our
+// peers list to the active peers.
 func (bc *BlockchainStruct) DialAndUpdatePeers() {
 	for {
-		log.Println("Pinging Peers", bc.Peers)
-		newList := bc.Peers
-
-		for peer := range newList {
-			if peer != bc.Address {
-				newList[peer] = bc.CheckStatus(peer)
-			} else {
-				newList[peer] = true
-			}
+		peers := bc.Peers.ToDial()
+		log.Println("Pinging Peers", peers)
+
+		var wg sync.WaitGroup
+		for _, peer := range peers {
+			wg.Add(1)
+			go func(peer string) {
+				defer wg.Done()
+				bc.checkPeer(peer)
+			}(peer)
 		}
+		wg.Wait()
 
-		// update our peers List
-		bc.UpdatePeers(newList)
-		log.Println("Updated Peer status : ", bc.Peers)
+		bc.savePeers()
+		log.Println("Active peers:", bc.activePeers())
 
 		// broadcast our new peers list
 		bc.BroadcastPeerList()
 
-		time.Sleep(constants.PEER_PING_PAUSE_TIME * time.Second)
+		time.Sleep(time.Duration(bc.Config.PeerPingPauseTime) * time.Second)
 	}
 }
 
 // For transaction
 
 func (bc *BlockchainStruct) SendTxnToThePeer(address string, txn *Transaction) {
-	data := txn.ToJson()
-	ourURL := fmt.Sprintf("%s/send_txn", address)
-	http.Post(ourURL, "application/json", strings.NewReader(data))
+	bc.SeenTxns.Add(txn.TransactionHash, address)
+	bc.Gossip.Send(address, "/send_txn", []byte(txn.ToJson()))
 }
 
+// BroadcastTransaction queues txn for every peer not known to have it yet.
 func (bc *BlockchainStruct) BroadcastTransaction(txn *Transaction) {
-	for peer, status := range bc.Peers {
-		if peer != bc.Address && status {
-			log.Println("Broadcasting transaction to the peer:", peer, "Transaction:", txn.ToJson())
+	log.Println("Broadcasting transaction:", txn.TransactionHash)
+	for _, peer := range bc.activePeers() {
+		if !bc.SeenTxns.Has(txn.TransactionHash, peer) {
 			bc.SendTxnToThePeer(peer, txn)
-			time.Sleep(constants.TXN_BROADCAST_PAUSE_TIME * time.Second)
 		}
 	}
 }
 
-func FetchLastNBlocks(address string) (*BlockchainStruct, error) {
-	log.Println("Fetching last", constants.FETCH_LAST_N_BLOCKS, "blocks")
-	ourURL := fmt.Sprintf("%s/fetch_last_n_blocks", address)
-	resp, err := http.Get(ourURL)
-	if err != nil {
-		return nil, err
-	}
-
-	data, err := ioutil.ReadAll(resp.Body)
-	if err != nil {
-		return nil, err
-	}
-	defer resp.Body.Close()
+// LastBlocks is the answer to /fetch_last_n_blocks, in the format the whole
+// chain used to be sent in.
+type LastBlocks struct {
+	Blocks []*Block `json:"block_chain"`
+}
 
-	var nbc BlockchainStruct
-	err = json.Unmarshal(data, &nbc)
+func FetchLastNBlocks(address string) (*LastBlocks, error) {
+	log.Println("Fetching last blocks from", address)
+	var last LastBlocks
+	err := getJson(fmt.Sprintf("%s/fetch_last_n_blocks", address), &last)
 	if err != nil {
 		return nil, err
 	}
 
-	return &nbc, nil
-}
-
-func verifyLastNBlocks(chain []*Block) bool {
-	if chain[0].BlockNumber != 0 && chain[0].Hash()[2:2+constants.MINING_DIFFICULTY] != strings.Repeat("0", constants.MINING_DIFFICULTY) {
-		log.Println("Chain verification failed for block", chain[0].BlockNumber, "hash", chain[0].Hash())
-		return false
-	}
-
-	for i := 1; i < len(chain); i++ {
-		if chain[i-1].Hash() != chain[i].PrevHash {
-			log.Println("Failed to verify prevHash for block number", chain[i].BlockNumber)
-			return false
-		}
-
-		if chain[i].Hash()[2:2+constants.MINING_DIFFICULTY] != strings.Repeat("0", constants.MINING_DIFFICULTY) {
-			log.Println("Chain verification failed for block", chain[0].BlockNumber, "hash", chain[0].Hash())
-			return false
-		}
-	}
-
-	return true
-}
-
-func (bc *BlockchainStruct) UpdateBlockchain(chain []*Block) {
-	mutex.Lock()
-	defer mutex.Unlock()
-
-	blocks := []*Block{}
-	initIdx := chain[0].BlockNumber
-	log.Println("Updating our blockchain from block number", initIdx)
-	blocks = append(blocks, bc.Blocks[:initIdx]...)
-	blocks = append(blocks, chain...)
-
-	bc.Blocks = blocks
-
-	// update the transaction pool
-	found := map[string]bool{}
-	for _, txn := range bc.TransactionPool {
-		found[txn.TransactionHash] = false
-	}
-
-	for _, block := range chain {
-		for _, txn := range block.Transactions {
-			_, ok := found[txn.TransactionHash]
-			if ok {
-				found[txn.TransactionHash] = true
-			}
-		}
-	}
-
-	newTxnPool := []*Transaction{}
-	for _, txn := range bc.TransactionPool {
-		if !found[txn.TransactionHash] {
-			newTxnPool = append(newTxnPool, txn)
-		}
-	}
-
-	bc.TransactionPool = newTxnPool
-
-	// save the blockchain in the database
-	err := PutIntoDb(*bc)
-	if err != nil {
-		panic(err.Error())
-	}
+	return &last, nil
 }
 
+// RunConsensus pulls the last blocks of every peer. Fork choice happens in
+// ProcessBlocks, which follows the branch with the most total work.
 func (bc *BlockchainStruct) RunConsensus() {
 
 	for {
 		log.Println("Starting the consensus algorithm...")
-		longestChain := bc.Blocks
-		lengthOfTheLongestChain := bc.Blocks[len(bc.Blocks)-1].BlockNumber + 1
-		longestChainIsOur := true
-		for peer, status := range bc.Peers {
-			if peer != bc.Address && status {
-				bc1, err := FetchLastNBlocks(peer)
-				if err != nil {
-					log.Println("Error while  fetching last n blocks from peer:", peer, "Error:", err.Error())
-					continue
-				}
-
-				lengthOfTheFetchedChain := bc1.Blocks[len(bc1.Blocks)-1].BlockNumber + 1
-				if lengthOfTheFetchedChain > lengthOfTheLongestChain {
-					longestChain = bc1.Blocks
-					lengthOfTheLongestChain = lengthOfTheFetchedChain
-					longestChainIsOur = false
-				}
+		for _, peer := range bc.activePeers() {
+			last, err := FetchLastNBlocks(peer)
+			if err != nil {
+				log.Println("Error while  fetching last n blocks from peer:", peer, "Error:", err.Error())
+				continue
 			}
-		}
-
-		if longestChainIsOur {
-			log.Println("My chain is longest, thus I am not updating my blockchain")
-			time.Sleep(constants.CONSENSUS_PAUSE_TIME * time.Second)
-			continue
-		}
 
-		if verifyLastNBlocks(longestChain) {
-			// stop the Mining until updation
-			bc.MiningLocked = true
-			bc.UpdateBlockchain(longestChain)
-			// restart the Mining as updation is complete
-			bc.MiningLocked = false
-			log.Println("Updation of Blockchain complete !!!")
-		} else {
-			log.Println("Chain Verification Failed, Hence not updating my blockchain")
+			err = bc.ProcessBlocks(last.Blocks)
+			if errors.Is(err, ErrInvalidBlock) {
+				bc.PeerMisbehaved(addrbook.HostOf(peer), constants.INVALID_BLOCK_SCORE, err.Error())
+			} else if err != nil {
+				log.Println("Error while processing blocks from peer:", peer, "Error:", err.Error())
+			}
 		}
 
-		time.Sleep(constants.CONSENSUS_PAUSE_TIME * time.Second)
+		time.Sleep(time.Duration(bc.Config.ConsensusPauseTime) * time.Second)
 	}
 
 }
@@ -0,0 +1,138 @@
+package blockchain
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/sap200/evochain/constants"
+)
+
+func TestPendingStateFollowsThePool(t *testing.T) {
+	sender := newTestKey(t)
+	receiver := newTestKey(t)
+	bc := newTestChain(t, map[string]uint64{sender.address: 100})
+
+	for nonce := uint64(0); nonce < 3; nonce++ {
+		err := bc.AddTransactionToTransactionPool(sender.transfer(t, bc.Params, receiver.address, 20, 1, nonce), "")
+		if err != nil {
+			t.Fatalf("transaction %d refused: %v", nonce, err)
+		}
+	}
+	if nonce := bc.GetNextNonce(sender.address); nonce != 3 {
+		t.Fatalf("GetNextNonce() = %d, want 3", nonce)
+	}
+
+	// 63 of 100 are pending, a fourth transfer of 40 does not fit
+	err := bc.AddTransactionToTransactionPool(sender.transfer(t, bc.Params, receiver.address, 40, 1, 3), "")
+	if !errors.Is(err, ErrTxnInsufficientFunds) {
+		t.Fatalf("AddTransactionToTransactionPool() error = %v, want ErrTxnInsufficientFunds", err)
+	}
+
+	// a replacement is checked against the funds before the one it replaces
+	replacement := sender.transfer(t, bc.Params, receiver.address, 55, 5, 1)
+	err = bc.AddTransactionToTransactionPool(replacement, "")
+	if err != nil {
+		t.Fatalf("replacement refused: %v", err)
+	}
+	if !bc.Pool.Has(replacement.TransactionHash) {
+		t.Fatal("the replacement is not in the pool")
+	}
+	// 21 + 60 leave 19, too little for the transaction after the replaced one
+	if nonce := bc.GetNextNonce(sender.address); nonce != 2 {
+		t.Fatalf("GetNextNonce() after the replacement = %d, want 2", nonce)
+	}
+
+	b := mineOn(bc.Params, canonicalChain(t, bc), receiver.address, bc.Pool.BySender(sender.address)[0])
+	err = bc.AddBlock(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if nonce := bc.GetNextNonce(sender.address); nonce != 2 {
+		t.Fatalf("GetNextNonce() after a block = %d, want 2", nonce)
+	}
+}
+
+func TestPoolSignaturesAreVerifiedOnce(t *testing.T) {
+	sender := newTestKey(t)
+	bc := newTestChain(t, map[string]uint64{sender.address: 100})
+
+	first := sender.transfer(t, bc.Params, newTestKey(t).address, 10, 1, 0)
+	err := bc.AddTransactionToTransactionPool(first, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// had the pool been verified again, the next nonce would be 0
+	pooled, _ := bc.Pool.Get(first.TransactionHash)
+	pooled.Signature = []byte{}
+	err = bc.AddTransactionToTransactionPool(sender.transfer(t, bc.Params, newTestKey(t).address, 10, 1, 1), "")
+	if err != nil {
+		t.Fatalf("AddTransactionToTransactionPool() error = %v", err)
+	}
+}
+
+func TestAddTransactionRejectsInvalidTransactions(t *testing.T) {
+	sender := newTestKey(t)
+	receiver := newTestKey(t)
+	bc := newTestChain(t, map[string]uint64{sender.address: 1000})
+	otherChain := *bc.Params
+	otherChain.ChainId++
+
+	tests := []struct {
+		name string
+		txn  func() *Transaction
+		want error
+	}{
+		{"bad hash", func() *Transaction {
+			txn := sender.transfer(t, bc.Params, receiver.address, 10, 1, 0)
+			txn.TransactionHash = "0x01"
+			return txn
+		}, ErrTxnBadHash},
+		{"zero value", func() *Transaction {
+			return sender.transfer(t, bc.Params, receiver.address, 0, 1, 0)
+		}, ErrTxnZeroValue},
+		{"self transfer", func() *Transaction {
+			return sender.transfer(t, bc.Params, sender.address, 10, 1, 0)
+		}, ErrTxnSelfTransfer},
+		{"other chain", func() *Transaction {
+			return sender.transfer(t, &otherChain, receiver.address, 10, 1, 0)
+		}, ErrTxnWrongChain},
+		{"key of another address", func() *Transaction {
+			txn := sender.transfer(t, bc.Params, receiver.address, 10, 1, 0)
+			txn.From = newTestKey(t).address
+			txn.TransactionHash = txn.Hash()
+			return txn
+		}, ErrTxnWrongSender},
+		{"bad signature", func() *Transaction {
+			txn := sender.transfer(t, bc.Params, receiver.address, 10, 1, 0)
+			txn.Signature = sender.transfer(t, bc.Params, receiver.address, 11, 1, 0).Signature
+			return txn
+		}, ErrTxnBadSignature},
+		{"nonce ahead", func() *Transaction {
+			return sender.transfer(t, bc.Params, receiver.address, 10, 1, 1)
+		}, ErrTxnBadNonce},
+		{"insufficient funds", func() *Transaction {
+			return sender.transfer(t, bc.Params, receiver.address, 1000, 1, 0)
+		}, ErrTxnInsufficientFunds},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			txn := tt.txn()
+			err := bc.AddTransactionToTransactionPool(txn, "")
+			if !errors.Is(err, tt.want) {
+				t.Fatalf("AddTransactionToTransactionPool() error = %v, want %v", err, tt.want)
+			}
+			if bc.Pool.Len() != 0 {
+				t.Fatal("an invalid transaction entered the pool")
+			}
+
+			receipt, err := bc.GetReceipt(txn.TransactionHash)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if receipt.Status != constants.RECEIPT_REJECTED || receipt.Reason != tt.want.Error() {
+				t.Fatalf("receipt = %+v, want rejected for %q", receipt, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,214 @@
+package blockchain
+
+import (
+	"encoding/hex"
+	"strconv"
+	"time"
+
+	"github.com/sap200/evochain/constants"
+	"github.com/sap200/evochain/mempool"
+)
+
+type BlockPage struct {
+	Blocks     []*Block `json:"blocks"`
+	NextCursor string   `json:"next_cursor,omitempty"`
+}
+
+// TransactionInfo is a transaction with where it sits in our chain. A
+// transaction still waiting in the pool has no block and no confirmations.
+type TransactionInfo struct {
+	Transaction   *Transaction `json:"transaction"`
+	InPool        bool         `json:"in_pool"`
+	BlockHash     string       `json:"block_hash,omitempty"`
+	BlockNumber   uint64       `json:"block_number"`
+	Index         int          `json:"index"`
+	Confirmations uint64       `json:"confirmations"`
+}
+
+type TransactionPage struct {
+	Transactions []*TransactionInfo `json:"transactions"`
+	NextCursor   string             `json:"next_cursor,omitempty"`
+}
+
+type PoolPage struct {
+	Transactions []*Transaction `json:"transactions"`
+	NextCursor   string         `json:"next_cursor,omitempty"`
+}
+
+func pageSize(limit int) int {
+	if limit <= 0 {
+		return constants.DEFAULT_PAGE_SIZE
+	}
+	if limit > constants.MAX_PAGE_SIZE {
+		return constants.MAX_PAGE_SIZE
+	}
+	return limit
+}
+
+func (bc *BlockchainStruct) height() uint64 {
+	return bc.tip.BlockNumber
+}
+
+// GetBlocks returns the canonical blocks from number from up to number to,
+// one page at a time. The cursor of the next page is the number of its first
+// block.
+func (bc *BlockchainStruct) GetBlocks(from uint64, to uint64, limit int) (*BlockPage, error) {
+	bc.mu.RLock()
+	defer bc.mu.RUnlock()
+
+	if height := bc.height(); to > height {
+		to = height
+	}
+
+	page := &BlockPage{Blocks: []*Block{}}
+	for number := from; number <= to; number++ {
+		if len(page.Blocks) == pageSize(limit) {
+			page.NextCursor = strconv.FormatUint(number, 10)
+			break
+		}
+
+		b, err := bc.Store.GetBlockByNumber(number)
+		if err != nil {
+			return nil, err
+		}
+		page.Blocks = append(page.Blocks, b)
+	}
+
+	return page, nil
+}
+
+// GetLatestBlocks returns the last n canonical blocks, newest first.
+func (bc *BlockchainStruct) GetLatestBlocks(n int) ([]*Block, error) {
+	bc.mu.RLock()
+	defer bc.mu.RUnlock()
+
+	blocks := []*Block{}
+	for number := int64(bc.height()); number >= 0 && len(blocks) < pageSize(n); number-- {
+		b, err := bc.Store.GetBlockByNumber(uint64(number))
+		if err != nil {
+			return nil, err
+		}
+		blocks = append(blocks, b)
+	}
+
+	return blocks, nil
+}
+
+// GetLastBlocks returns the last n blocks of our chain, oldest first.
+func (bc *BlockchainStruct) GetLastBlocks(n int) ([]*Block, error) {
+	bc.mu.RLock()
+	defer bc.mu.RUnlock()
+
+	from := uint64(0)
+	if uint64(n) <= bc.height() {
+		from = bc.height() - uint64(n) + 1
+	}
+	blocks := []*Block{}
+	err := bc.Store.IterateBlocks(from, func(b *Block) bool {
+		blocks = append(blocks, b)
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	return blocks, nil
+}
+
+func (bc *BlockchainStruct) transactionInfo(hash string) (*TransactionInfo, error) {
+	loc, err := bc.Store.GetTransactionLocation(hash)
+	if err != nil {
+		return nil, err
+	}
+
+	b, err := bc.Store.GetBlock(loc.BlockHash)
+	if err != nil {
+		return nil, err
+	}
+
+	return &TransactionInfo{
+		Transaction:   b.Transactions[loc.Index],
+		BlockHash:     loc.BlockHash,
+		BlockNumber:   loc.BlockNumber,
+		Index:         loc.Index,
+		Confirmations: bc.height() - loc.BlockNumber + 1,
+	}, nil
+}
+
+// GetTransaction looks a transaction up in our chain, then in the pool.
+func (bc *BlockchainStruct) GetTransaction(hash string) (*TransactionInfo, error) {
+	bc.mu.RLock()
+	defer bc.mu.RUnlock()
+
+	info, err := bc.transactionInfo(hash)
+	if err != ErrNotFound {
+		return info, err
+	}
+
+	if txn, ok := bc.Pool.Get(hash); ok {
+		return &TransactionInfo{Transaction: txn, InPool: true}, nil
+	}
+
+	return nil, ErrNotFound
+}
+
+// GetAddressTransactions returns the transactions of our chain sent or
+// received by address, newest first. The cursor is opaque to callers, it is
+// the index key the next page starts at.
+func (bc *BlockchainStruct) GetAddressTransactions(address string, cursor string, limit int) (*TransactionPage, error) {
+	bc.mu.RLock()
+	defer bc.mu.RUnlock()
+
+	prefix := addressTxnPrefix(address)
+	start := prefix
+	if cursor != "" {
+		suffix, err := hex.DecodeString(cursor)
+		if err != nil {
+			return nil, err
+		}
+		start = append(append([]byte{}, prefix...), suffix...)
+	}
+
+	page := &TransactionPage{Transactions: []*TransactionInfo{}}
+	hashes := []string{}
+	err := bc.Store.IteratePrefixFrom(start, prefix, func(key, value []byte) bool {
+		if len(hashes) == pageSize(limit) {
+			page.NextCursor = hex.EncodeToString(key[len(prefix):])
+			return false
+		}
+		hashes = append(hashes, string(value))
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for _, hash := range hashes {
+		info, err := bc.transactionInfo(hash)
+		if err != nil {
+			return nil, err
+		}
+		page.Transactions = append(page.Transactions, info)
+	}
+
+	return page, nil
+}
+
+// GetPoolTransactions lists the transaction pool in arrival order, optionally
+// only the transactions of sender and those paying at least minFee. The
+// cursor of the next page is the position of the last transaction returned.
+func (bc *BlockchainStruct) GetPoolTransactions(sender string, minFee uint64, cursor uint64, limit int) *PoolPage {
+	filter := func(txn *Transaction) bool {
+		return (sender == "" || txn.From == sender) && txn.Fee >= minFee
+	}
+
+	txns, next := bc.Pool.List(filter, cursor, pageSize(limit))
+	page := &PoolPage{Transactions: txns}
+	if next != 0 {
+		page.NextCursor = strconv.FormatUint(next, 10)
+	}
+	return page
+}
+
+func (bc *BlockchainStruct) GetPoolStats() mempool.Stats {
+	return bc.Pool.Stats(time.Now())
+}
@@ -0,0 +1,120 @@
+package blockchain
+
+import (
+	"testing"
+)
+
+func TestGetBlocksPages(t *testing.T) {
+	bc := newTestChain(t, nil)
+	miner := newTestKey(t)
+	for i := 0; i < 6; i++ {
+		err := bc.ProcessBlocks([]*Block{mineOn(bc.Params, canonicalChain(t, bc), miner.address)})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name       string
+		from       uint64
+		to         uint64
+		limit      int
+		numbers    []uint64
+		nextCursor string
+	}{
+		{"first page", 0, 100, 4, []uint64{0, 1, 2, 3}, "4"},
+		{"last page", 4, 100, 4, []uint64{4, 5, 6}, ""},
+		{"range filling the page", 0, 3, 4, []uint64{0, 1, 2, 3}, ""},
+		{"range inside the chain", 2, 4, 0, []uint64{2, 3, 4}, ""},
+		{"past the tip", 7, 100, 4, []uint64{}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			page, err := bc.GetBlocks(tt.from, tt.to, tt.limit)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(page.Blocks) != len(tt.numbers) {
+				t.Fatalf("GetBlocks() returned %d blocks, want %d", len(page.Blocks), len(tt.numbers))
+			}
+			for i, b := range page.Blocks {
+				if b.BlockNumber != tt.numbers[i] {
+					t.Fatalf("block %d is number %d, want %d", i, b.BlockNumber, tt.numbers[i])
+				}
+			}
+			if page.NextCursor != tt.nextCursor {
+				t.Fatalf("NextCursor = %q, want %q", page.NextCursor, tt.nextCursor)
+			}
+		})
+	}
+}
+
+func TestGetAddressTransactionsPages(t *testing.T) {
+	sender := newTestKey(t)
+	receiver := newTestKey(t)
+	bc := newTestChain(t, map[string]uint64{sender.address: 1000})
+
+	nonce := uint64(0)
+	for _, count := range []int{3, 2} {
+		txns := []*Transaction{}
+		for i := 0; i < count; i++ {
+			txns = append(txns, sender.transfer(t, bc.Params, receiver.address, 10, 1, nonce))
+			nonce++
+		}
+		err := bc.ProcessBlocks([]*Block{mineOn(bc.Params, canonicalChain(t, bc), newTestKey(t).address, txns...)})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, address := range []string{sender.address, receiver.address} {
+		nonces := []uint64{}
+		cursor := ""
+		for pages := 1; ; pages++ {
+			page, err := bc.GetAddressTransactions(address, cursor, 2)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(page.Transactions) > 2 {
+				t.Fatalf("page %d holds %d transactions, want at most 2", pages, len(page.Transactions))
+			}
+			for _, info := range page.Transactions {
+				// the genesis allocation funding the sender comes last
+				if info.BlockNumber == 0 && address == sender.address {
+					continue
+				}
+				if info.Transaction.From != sender.address || info.Transaction.To != receiver.address {
+					t.Fatalf("listed %s for %s, which neither sent nor received it", info.Transaction.TransactionHash, address)
+				}
+				nonces = append(nonces, info.Transaction.Nonce)
+			}
+
+			cursor = page.NextCursor
+			if cursor == "" {
+				if pages != 3 {
+					t.Fatalf("listed the transactions in %d pages, want 3", pages)
+				}
+				break
+			}
+		}
+
+		// newest first, across blocks and within a block
+		for i, n := range nonces {
+			if n != uint64(len(nonces)-1-i) {
+				t.Fatalf("nonces of the transactions of %s = %v, want newest first", address, nonces)
+			}
+		}
+		if len(nonces) != 5 {
+			t.Fatalf("listed %d transactions of %s, want 5", len(nonces), address)
+		}
+	}
+
+	page, err := bc.GetAddressTransactions(newTestKey(t).address, "", 0)
+	if err != nil || len(page.Transactions) != 0 || page.NextCursor != "" {
+		t.Fatalf("GetAddressTransactions() of an unknown address = %+v, %v", page, err)
+	}
+	_, err = bc.GetAddressTransactions(sender.address, "not hex", 2)
+	if err == nil {
+		t.Fatal("GetAddressTransactions() accepted a malformed cursor")
+	}
+}
@@ -0,0 +1,95 @@
+package blockchain
+
+import (
+	"sync"
+
+	"github.com/sap200/evochain/constants"
+)
+
+// Receipt tells where a transaction is in its life: waiting in the pool,
+// rejected by this node, or in a block of our chain. Blocks only hold
+// transactions that took effect, so an included transaction never failed.
+type Receipt struct {
+	TransactionHash string `json:"transaction_hash"`
+	Status          string `json:"status"`
+	Reason          string `json:"reason,omitempty"`
+	BlockHash       string `json:"block_hash,omitempty"`
+	BlockNumber     uint64 `json:"block_number"`
+	Index           int    `json:"index"`
+	Fee             uint64 `json:"fee"`
+	Confirmations   uint64 `json:"confirmations"`
+}
+
+// RejectionLog remembers why the most recent transactions were refused by
+// this node, oldest are forgotten first.
+type RejectionLog struct {
+	mu      sync.Mutex
+	reasons map[string]string
+	order   []string
+}
+
+func NewRejectionLog() *RejectionLog {
+	l := new(RejectionLog)
+	l.reasons = map[string]string{}
+	l.order = []string{}
+	return l
+}
+
+func (l *RejectionLog) Add(hash string, reason string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if _, ok := l.reasons[hash]; !ok {
+		l.order = append(l.order, hash)
+	}
+	l.reasons[hash] = reason
+
+	if len(l.order) > constants.MAX_REJECTED_TXNS {
+		delete(l.reasons, l.order[0])
+		l.order = l.order[1:]
+	}
+}
+
+func (l *RejectionLog) Get(hash string) (string, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	reason, ok := l.reasons[hash]
+	return reason, ok
+}
+
+// GetReceipt looks a transaction up in our chain, the pool and the
+// rejections, in that order.
+func (bc *BlockchainStruct) GetReceipt(hash string) (*Receipt, error) {
+	bc.mu.RLock()
+	defer bc.mu.RUnlock()
+
+	receipt := &Receipt{TransactionHash: hash}
+	info, err := bc.transactionInfo(hash)
+	if err == nil {
+		receipt.Status = constants.RECEIPT_INCLUDED
+		receipt.BlockHash = info.BlockHash
+		receipt.BlockNumber = info.BlockNumber
+		receipt.Index = info.Index
+		receipt.Fee = info.Transaction.Fee
+		receipt.Confirmations = info.Confirmations
+		return receipt, nil
+	}
+	if err != ErrNotFound {
+		return nil, err
+	}
+
+	if txn, ok := bc.Pool.Get(hash); ok {
+		receipt.Status = constants.RECEIPT_PENDING
+		receipt.Fee = txn.Fee
+		return receipt, nil
+	}
+
+	if reason, rejected := bc.Rejections.Get(hash); rejected {
+		receipt.Status = constants.RECEIPT_REJECTED
+		receipt.Reason = reason
+		return receipt, nil
+	}
+
+	return nil, ErrNotFound
+}
@@ -0,0 +1,82 @@
+package blockchain
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/sap200/evochain/constants"
+)
+
+func TestGetReceiptFollowsTheTransaction(t *testing.T) {
+	sender := newTestKey(t)
+	receiver := newTestKey(t)
+	bc := newTestChain(t, map[string]uint64{sender.address: 1000})
+
+	first := sender.transfer(t, bc.Params, receiver.address, 10, 2, 0)
+	second := sender.transfer(t, bc.Params, receiver.address, 10, 3, 1)
+	if _, err := bc.GetReceipt(first.TransactionHash); err != ErrNotFound {
+		t.Fatalf("GetReceipt() of an unknown transaction error = %v, want ErrNotFound", err)
+	}
+
+	// the second is refused until the first takes its nonce
+	if err := bc.AddTransactionToTransactionPool(second, ""); err == nil {
+		t.Fatal("AddTransactionToTransactionPool() took a nonce ahead of the sender")
+	}
+	receipt, err := bc.GetReceipt(second.TransactionHash)
+	if err != nil || receipt.Status != constants.RECEIPT_REJECTED || receipt.Reason == "" {
+		t.Fatalf("receipt = %+v, %v, want rejected with a reason", receipt, err)
+	}
+
+	for _, txn := range []*Transaction{first, second} {
+		if err := bc.AddTransactionToTransactionPool(txn, ""); err != nil {
+			t.Fatal(err)
+		}
+		receipt, err := bc.GetReceipt(txn.TransactionHash)
+		if err != nil || receipt.Status != constants.RECEIPT_PENDING || receipt.Fee != txn.Fee || receipt.BlockHash != "" {
+			t.Fatalf("receipt = %+v, %v, want pending", receipt, err)
+		}
+	}
+
+	b := mineOn(bc.Params, canonicalChain(t, bc), sender.address, first, second)
+	if err := bc.ProcessBlocks([]*Block{b}); err != nil {
+		t.Fatal(err)
+	}
+	if err := bc.ProcessBlocks([]*Block{mineOn(bc.Params, canonicalChain(t, bc), sender.address)}); err != nil {
+		t.Fatal(err)
+	}
+	receipt, err = bc.GetReceipt(second.TransactionHash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Receipt{
+		TransactionHash: second.TransactionHash,
+		Status:          constants.RECEIPT_INCLUDED,
+		BlockHash:       b.Hash(),
+		BlockNumber:     1,
+		Index:           1,
+		Fee:             second.Fee,
+		Confirmations:   2,
+	}
+	if *receipt != want {
+		t.Fatalf("receipt = %+v, want %+v", receipt, want)
+	}
+}
+
+func TestRejectionLogForgetsTheOldest(t *testing.T) {
+	l := NewRejectionLog()
+	for i := 0; i <= constants.MAX_REJECTED_TXNS; i++ {
+		l.Add(fmt.Sprint(i), "reason")
+	}
+	// a repeated rejection updates the reason without taking a new place
+	l.Add("1", "another reason")
+
+	if _, ok := l.Get("0"); ok {
+		t.Fatal("the oldest rejection was kept past the size of the log")
+	}
+	if reason, ok := l.Get("1"); !ok || reason != "another reason" {
+		t.Fatalf("Get() = %q, %v, want the latest reason", reason, ok)
+	}
+	if _, ok := l.Get(fmt.Sprint(constants.MAX_REJECTED_TXNS)); !ok {
+		t.Fatal("the newest rejection was forgotten")
+	}
+}
@@ -0,0 +1,258 @@
+package blockchain
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"time"
+
+	"github.com/sap200/evochain/constants"
+)
+
+var (
+	ErrUnknownParent = errors.New("blocks do not connect to any block we know")
+	ErrInvalidBlock  = errors.New("invalid block")
+)
+
+func (bc *BlockchainStruct) isCanonical(b *Block) bool {
+	hash, err := bc.Store.Get(blockNumberKey(b.BlockNumber))
+	return err == nil && string(hash) == b.Hash()
+}
+
+// sideBranch walks back from the stored block hash to our chain and returns
+// the side branch blocks in chain order along with the number of the
+// canonical block they fork from. The branch is empty for a canonical hash.
+func (bc *BlockchainStruct) sideBranch(hash string) ([]*Block, uint64, error) {
+	branch := []*Block{}
+	for {
+		b, err := bc.Store.GetBlock(hash)
+		if err != nil {
+			return nil, 0, err
+		}
+
+		if bc.isCanonical(b) {
+			for i, j := 0, len(branch)-1; i < j; i, j = i+1, j-1 {
+				branch[i], branch[j] = branch[j], branch[i]
+			}
+			return branch, b.BlockNumber, nil
+		}
+
+		branch = append(branch, b)
+		hash = b.PrevHash
+	}
+}
+
+// ancestorsOf returns the headers up to the stored block hash NextTarget
+// needs, the side branch blocks among them and the canonical block the
+// branch forks from.
+func (bc *BlockchainStruct) ancestorsOf(hash string) ([]BlockHeader, []*Block, uint64, error) {
+	side, fork, err := bc.sideBranch(hash)
+	if err != nil {
+		return nil, nil, 0, err
+	}
+
+	ancestors, err := bc.headersUpTo(fork)
+	if err != nil {
+		return nil, nil, 0, err
+	}
+	for _, b := range side {
+		ancestors = append(ancestors, b.BlockHeader)
+	}
+	return ancestors, side, fork, nil
+}
+
+// ProcessBlocks takes consecutive blocks from a peer. Blocks we do not have
+// yet are validated on top of their parent, which may sit on a side branch,
+// and stored with their total work. When the branch ends up with more work
+// than our chain we reorganize onto it.
+func (bc *BlockchainStruct) ProcessBlocks(blocks []*Block) error {
+	bc.mu.Lock()
+	defer bc.mu.Unlock()
+
+	i := 0
+	for i < len(blocks) && HasBlockInDb(bc.Store, blocks[i].Hash()) {
+		i++
+	}
+	if i == len(blocks) {
+		return nil
+	}
+	blocks = blocks[i:]
+
+	if !HasBlockInDb(bc.Store, blocks[0].PrevHash) {
+		return ErrUnknownParent
+	}
+
+	ancestors, side, fork, err := bc.ancestorsOf(blocks[0].PrevHash)
+	if err != nil {
+		return err
+	}
+
+	state := bc.stateAt(fork)
+	for _, b := range side {
+		state.ApplyBlock(b)
+	}
+
+	totalWork, err := GetTotalWorkFromDb(bc.Store, blocks[0].PrevHash)
+	if err != nil {
+		return err
+	}
+
+	batch := bc.Store.NewBatch()
+	valid := []*Block{}
+	var validationErr error
+	for _, b := range blocks {
+		target, err := NextTarget(bc.Params, ancestors)
+		if err == nil {
+			err = ValidateBlock(bc.Params, state, b, target)
+		}
+		if err != nil {
+			log.Println("Block validation failed:", err.Error())
+			validationErr = fmt.Errorf("%w: %s", ErrInvalidBlock, err.Error())
+			break
+		}
+
+		state.ApplyBlock(b)
+		ancestors = append(ancestors, b.BlockHeader)
+		totalWork = new(big.Int).Add(totalWork, BlockWork(b))
+		batch.PutBlock(b)
+		batch.SetTotalWork(b, totalWork)
+		valid = append(valid, b)
+	}
+
+	if len(valid) == 0 {
+		return validationErr
+	}
+
+	err = bc.Store.Write(batch)
+	if err != nil {
+		return err
+	}
+
+	ourWork, err := GetTotalWorkFromDb(bc.Store, bc.tip.Hash())
+	if err != nil {
+		return err
+	}
+
+	if totalWork.Cmp(ourWork) > 0 {
+		err = bc.reorganize(append(side, valid...))
+		if err != nil {
+			return err
+		}
+	} else {
+		log.Println("Stored", len(valid), "side branch blocks with less work than our chain")
+	}
+
+	return validationErr
+}
+
+// reorganize makes branch, whose first block's parent is on our chain, the
+// tip of our chain. Our blocks after the common ancestor are unwound and
+// their transactions that the branch does not include go back to the
+// transaction pool. Must be called with the chain mutex held.
+func (bc *BlockchainStruct) reorganize(branch []*Block) error {
+	fork := branch[0].BlockNumber - 1
+	oldTip := bc.tip
+	newTip := branch[len(branch)-1]
+
+	oldSuffix := []*Block{}
+	err := bc.Store.IterateBlocks(fork+1, func(b *Block) bool {
+		oldSuffix = append(oldSuffix, b)
+		return true
+	})
+	if err != nil {
+		return err
+	}
+	headers, err := bc.headersUpTo(fork)
+	if err != nil {
+		return err
+	}
+	for _, b := range branch {
+		headers = append(headers, b.BlockHeader)
+	}
+
+	// unwind the account state to the common ancestor and apply the branch
+	state := bc.stateAt(fork)
+	undos := []map[string]Account{}
+	included := map[string]bool{}
+	for _, b := range branch {
+		undos = append(undos, state.ApplyBlockWithUndo(b))
+		for _, txn := range b.Transactions {
+			included[txn.TransactionHash] = true
+		}
+	}
+
+	// return orphaned transactions to the pool ahead of the pending ones
+	orphaned := []*Transaction{}
+	for _, b := range oldSuffix {
+		for _, txn := range b.Transactions {
+			if txn.From != constants.BLOCKCHAIN_ADDRESS && txn.Status == constants.SUCCESS && !included[txn.TransactionHash] {
+				orphan := *txn
+				orphan.Status = constants.TXN_VERIFICATION_SUCCESS
+				orphaned = append(orphaned, &orphan)
+				included[txn.TransactionHash] = true
+			}
+		}
+	}
+
+	if len(oldSuffix) == 0 {
+		for _, b := range branch {
+			for _, txn := range b.Transactions {
+				bc.Pool.Remove(txn.TransactionHash)
+			}
+		}
+	} else {
+		bc.Pool.Requeue(orphaned, func(txn *Transaction) bool {
+			return included[txn.TransactionHash]
+		}, time.Now())
+	}
+	bc.validPool(state)
+	bc.setTip(newTip, headers)
+
+	// swap the replaced blocks in the database, the old ones stay readable by hash
+	batch := bc.Store.NewBatch()
+	for _, b := range oldSuffix {
+		batch.UnsetCanonical(b)
+	}
+	for i, b := range branch {
+		batch.PutBlock(b)
+		batch.SetCanonical(b)
+		batch.SetUndo(b, undos[i])
+	}
+	batch.SetTip(newTip)
+	batch.SetAccounts(state.Accounts)
+	batch.SetStateTip(newTip)
+	batch.SetTransactionPool(bc.Pool.Txns())
+	err = bc.Store.Write(batch)
+	if err != nil {
+		panic(err.Error())
+	}
+
+	if len(oldSuffix) > 0 {
+		reorg := new(ReorgEvent)
+		reorg.CommonAncestor = fork
+		reorg.OldTip = oldTip.Hash()
+		reorg.NewTip = newTip.Hash()
+		reorg.Removed = []string{}
+		reorg.Added = []string{}
+		reorg.Orphaned = []string{}
+		for _, b := range oldSuffix {
+			reorg.Removed = append(reorg.Removed, b.Hash())
+		}
+		for _, b := range branch {
+			reorg.Added = append(reorg.Added, b.Hash())
+		}
+		for _, txn := range orphaned {
+			reorg.Orphaned = append(reorg.Orphaned, txn.TransactionHash)
+		}
+
+		log.Println("Reorganized from block", oldTip.BlockNumber, reorg.OldTip, "to block", newTip.BlockNumber, reorg.NewTip,
+			"common ancestor", fork, "removed", len(oldSuffix), "added", len(branch), "orphaned transactions", len(orphaned))
+		bc.Events.Publish(ChainEvent{Type: constants.EVENT_REORG, BlockHash: reorg.NewTip, BlockNumber: newTip.BlockNumber, Reorg: reorg})
+	} else {
+		log.Println("Extended our chain to block", newTip.BlockNumber, "with", len(branch), "blocks")
+	}
+
+	bc.Events.Publish(ChainEvent{Type: constants.EVENT_NEW_TIP, BlockHash: newTip.Hash(), BlockNumber: newTip.BlockNumber})
+	return nil
+}
@@ -0,0 +1,179 @@
+package blockchain
+
+import (
+	"testing"
+	"time"
+
+	"github.com/sap200/evochain/constants"
+)
+
+func TestProcessBlocksFollowsTheMostWork(t *testing.T) {
+	sender := newTestKey(t)
+	receiver := newTestKey(t)
+	bc := newTestChain(t, map[string]uint64{sender.address: 1000})
+	genesis := canonicalChain(t, bc)[0]
+
+	txn := sender.transfer(t, bc.Params, receiver.address, 100, 1, 0)
+	err := bc.AddTransactionToTransactionPool(txn, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, txns := range [][]*Transaction{{txn}, {}} {
+		err := bc.ProcessBlocks([]*Block{mineOn(bc.Params, canonicalChain(t, bc), sender.address, txns...)})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	ours := bc.tip.Hash()
+	if bc.Pool.Has(txn.TransactionHash) {
+		t.Fatal("the mined transaction is still in the pool")
+	}
+
+	// a branch with as much work as ours is stored but not followed
+	miner := newTestKey(t)
+	branch := []*Block{genesis}
+	for len(branch) < 3 {
+		branch = append(branch, mineOn(bc.Params, branch, miner.address))
+	}
+	err = bc.ProcessBlocks(branch[1:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tip := bc.tip.Hash(); tip != ours {
+		t.Fatalf("reorganized onto a branch with no more work than ours, tip %s", tip)
+	}
+	if !HasBlockInDb(bc.Store, branch[2].Hash()) {
+		t.Fatal("the side branch was not stored")
+	}
+
+	// one more block gives it more work
+	branch = append(branch, mineOn(bc.Params, branch, miner.address))
+	err = bc.ProcessBlocks(branch[3:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tip := bc.tip.Hash(); tip != branch[3].Hash() {
+		t.Fatalf("tip = %s, want the tip of the branch with more work", tip)
+	}
+	if balance := bc.CalculateTotalCrypto(receiver.address); balance != 0 {
+		t.Fatalf("receiver balance = %d after its transfer was unwound, want 0", balance)
+	}
+	if balance := bc.CalculateTotalCrypto(miner.address); balance != 3*bc.Params.BlockReward(1) {
+		t.Fatalf("branch miner balance = %d, want %d", balance, 3*bc.Params.BlockReward(1))
+	}
+	if !bc.Pool.Has(txn.TransactionHash) {
+		t.Fatal("the orphaned transaction did not return to the pool")
+	}
+	if nonce := bc.GetNextNonce(sender.address); nonce != 1 {
+		t.Fatalf("GetNextNonce() = %d, want 1", nonce)
+	}
+
+	var reorg *ReorgEvent
+	for _, event := range bc.Events.Recent() {
+		if event.Type == constants.EVENT_REORG {
+			reorg = event.Reorg
+		}
+	}
+	if reorg == nil {
+		t.Fatal("no reorg event")
+	}
+	if reorg.CommonAncestor != 0 || reorg.OldTip != ours || len(reorg.Removed) != 2 || len(reorg.Added) != 3 {
+		t.Fatalf("reorg event = %+v", reorg)
+	}
+	if len(reorg.Orphaned) != 1 || reorg.Orphaned[0] != txn.TransactionHash {
+		t.Fatalf("orphaned transactions = %v, want %s", reorg.Orphaned, txn.TransactionHash)
+	}
+}
+
+func TestProcessBlocksKeepsThePendingTransactions(t *testing.T) {
+	sender := newTestKey(t)
+	receiver := newTestKey(t)
+	bc := newTestChain(t, map[string]uint64{sender.address: 1000})
+	genesis := canonicalChain(t, bc)[0]
+
+	added := time.Now().Add(-time.Minute)
+	_, _, err := bc.Pool.Add(sender.transfer(t, bc.Params, receiver.address, 100, 1, 0), added)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := func(when string) {
+		t.Helper()
+		stats := bc.Pool.Stats(added.Add(time.Minute))
+		if stats.Count != 1 || stats.OldestAge < 60 || stats.TotalAdded != 1 {
+			t.Fatalf("pool stats %s = %+v, want the transaction added once a minute ago", when, stats)
+		}
+	}
+
+	err = bc.ProcessBlocks([]*Block{mineOn(bc.Params, canonicalChain(t, bc), receiver.address)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want("after a block extending our chain")
+
+	branch := []*Block{genesis}
+	for len(branch) < 3 {
+		branch = append(branch, mineOn(bc.Params, branch, receiver.address))
+	}
+	err = bc.ProcessBlocks(branch[1:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bc.tip.Hash() != branch[2].Hash() {
+		t.Fatal("did not reorganize onto the branch with more work")
+	}
+	want("after a reorganization")
+}
+
+func TestChainKeepsOnlyTheRecentHeaders(t *testing.T) {
+	cfg := testConfig(t)
+	g := testGenesis(cfg, nil)
+	g.RetargetWindow = 2
+	store := NewMemoryStore()
+	err := InitBlockchain(store, g)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bc, err := NewBlockchain(store, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	genesis := canonicalChain(t, bc)[0]
+
+	miner := newTestKey(t)
+	for i := 0; i < 5; i++ {
+		err := bc.ProcessBlocks([]*Block{mineOn(bc.Params, canonicalChain(t, bc), miner.address)})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	checkHeaders := func(bc *BlockchainStruct, tip uint64) {
+		t.Helper()
+		if len(bc.headers) != 3 || bc.headers[0].BlockNumber != tip-2 || bc.headers[2].Hash() != bc.tip.Hash() || bc.tip.BlockNumber != tip {
+			t.Fatalf("kept headers %v with tip %d, want the 3 up to block %d", bc.headers, bc.tip.BlockNumber, tip)
+		}
+	}
+	checkHeaders(bc, 5)
+
+	reopened, err := NewBlockchain(store, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkHeaders(reopened, 5)
+
+	// a branch forking below the kept headers reads the older ones back
+	branch := []*Block{genesis}
+	for len(branch) < 7 {
+		branch = append(branch, mineOn(bc.Params, branch, newTestKey(t).address))
+	}
+	err = bc.ProcessBlocks(branch[1:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkHeaders(bc, 6)
+	if bc.tip.Hash() != branch[6].Hash() {
+		t.Fatalf("tip = %s, want the tip of the branch with more work", bc.tip.Hash())
+	}
+	if balance := bc.CalculateTotalCrypto(miner.address); balance != 0 {
+		t.Fatalf("miner balance = %d after its blocks were unwound, want 0", balance)
+	}
+}
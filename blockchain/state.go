@@ -0,0 +1,200 @@
+package blockchain
+
+import (
+	"github.com/sap200/evochain/address"
+	"github.com/sap200/evochain/constants"
+)
+
+type Account struct {
+	Balance uint64 `json:"balance"`
+	Nonce   uint64 `json:"nonce"`
+}
+
+// AccountState is the balance and nonce of every address after Parent was
+// applied. Blocks are validated against the state of their parent.
+//
+// Accounts only holds the accounts read or changed through this state. All
+// other accounts are read from the store the state is backed by, if any, so
+// a state on top of our tip is cheap to create and to copy.
+type AccountState struct {
+	Accounts map[string]*Account
+	Parent   *Block
+	store    Store
+}
+
+func NewAccountState() *AccountState {
+	state := new(AccountState)
+	state.Accounts = map[string]*Account{}
+	return state
+}
+
+// NewStoreAccountState returns the state persisted in store, which must be
+// the state after parent.
+func NewStoreAccountState(store Store, parent *Block) *AccountState {
+	state := NewAccountState()
+	state.store = store
+	state.Parent = parent
+	return state
+}
+
+func (s *AccountState) Copy() *AccountState {
+	ns := NewAccountState()
+	for address, account := range s.Accounts {
+		a := *account
+		ns.Accounts[address] = &a
+	}
+	ns.Parent = s.Parent
+	ns.store = s.store
+	return ns
+}
+
+func (s *AccountState) GetAccount(address string) Account {
+	account, ok := s.Accounts[address]
+	if ok {
+		return *account
+	}
+
+	if s.store != nil {
+		stored, err := GetAccountFromDb(s.store, address)
+		if err != nil {
+			panic(err.Error())
+		}
+		return stored
+	}
+
+	return Account{}
+}
+
+func (s *AccountState) account(address string) *Account {
+	account, ok := s.Accounts[address]
+	if !ok {
+		stored := s.GetAccount(address)
+		account = &stored
+		s.Accounts[address] = account
+	}
+	return account
+}
+
+// CheckTransaction returns why txn cannot be applied on top of the state of
+// chain chainId, or nil if it can. Coinbase transactions are not checked here.
+func (s *AccountState) CheckTransaction(txn *Transaction, chainId uint64) error {
+	err := VerifyTransaction(txn, chainId)
+	if err != nil {
+		return err
+	}
+	return s.checkFunds(txn)
+}
+
+// VerifyTransaction returns why txn is invalid on chain chainId whatever the
+// state, a bad hash or signature for instance, or nil if it is not.
+func VerifyTransaction(txn *Transaction, chainId uint64) error {
+	if txn.TransactionHash != txn.Hash() {
+		return ErrTxnBadHash
+	}
+
+	if txn.Value == 0 {
+		return ErrTxnZeroValue
+	}
+
+	if txn.From == txn.To {
+		return ErrTxnSelfTransfer
+	}
+
+	if !address.IsValid(txn.To) {
+		return ErrTxnBadRecipient
+	}
+
+	if txn.ChainId != chainId {
+		return ErrTxnWrongChain
+	}
+
+	if address.FromPublicKeyHex(txn.PublicKey) != txn.From {
+		return ErrTxnWrongSender
+	}
+
+	if !txn.VerifySignature(chainId) {
+		return ErrTxnBadSignature
+	}
+
+	return nil
+}
+
+// checkFunds returns why txn, already verified, cannot be applied on top of
+// the state.
+func (s *AccountState) checkFunds(txn *Transaction) error {
+	return checkSender(s.GetAccount(txn.From), txn)
+}
+
+// checkSender returns why sender cannot send txn: a nonce out of order or a
+// balance too low.
+func checkSender(sender Account, txn *Transaction) error {
+	if txn.Nonce != sender.Nonce {
+		return ErrTxnBadNonce
+	}
+
+	if txn.Value+txn.Fee < txn.Value || sender.Balance < txn.Value+txn.Fee {
+		return ErrTxnInsufficientFunds
+	}
+
+	return nil
+}
+
+// ApplyTransaction moves the funds of a transaction marked successful.
+func (s *AccountState) ApplyTransaction(txn *Transaction) {
+	if txn.Status != constants.SUCCESS {
+		return
+	}
+
+	s.applyTransfer(txn)
+}
+
+func (s *AccountState) applyTransfer(txn *Transaction) {
+	if txn.From != constants.BLOCKCHAIN_ADDRESS {
+		sender := s.account(txn.From)
+		sender.Balance -= txn.Value + txn.Fee
+		sender.Nonce++
+	}
+
+	receiver := s.account(txn.To)
+	receiver.Balance += txn.Value
+}
+
+func (s *AccountState) ApplyBlock(b *Block) {
+	for _, txn := range b.Transactions {
+		s.ApplyTransaction(txn)
+	}
+	s.Parent = b
+}
+
+// ApplyBlockWithUndo applies b and returns the accounts it touched as they
+// were before, which is what Revert needs to unwind b.
+func (s *AccountState) ApplyBlockWithUndo(b *Block) map[string]Account {
+	undo := map[string]Account{}
+	for _, txn := range b.Transactions {
+		if txn.Status != constants.SUCCESS {
+			continue
+		}
+		addresses := []string{txn.To}
+		if txn.From != constants.BLOCKCHAIN_ADDRESS {
+			addresses = append(addresses, txn.From)
+		}
+		for _, address := range addresses {
+			if _, ok := undo[address]; !ok {
+				undo[address] = s.GetAccount(address)
+			}
+		}
+	}
+
+	s.ApplyBlock(b)
+	return undo
+}
+
+// Revert unwinds the block on top of the state using its undo record,
+// leaving the state after parent.
+func (s *AccountState) Revert(undo map[string]Account, parent *Block) {
+	for address, account := range undo {
+		a := account
+		s.Accounts[address] = &a
+	}
+	s.Parent = parent
+}
@@ -0,0 +1,71 @@
+package blockchain
+
+import (
+	"github.com/syndtr/goleveldb/leveldb"
+	"github.com/syndtr/goleveldb/leveldb/util"
+)
+
+type levelDbBackend struct {
+	db *leveldb.DB
+}
+
+// OpenLevelDbStore opens the database at path once for the life of the
+// node, migrating a legacy single blob database if one is found.
+func OpenLevelDbStore(path string) (Store, error) {
+	db, err := leveldb.OpenFile(path, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	store := &kvStore{&levelDbBackend{db}}
+	err = migrateLegacyBlob(store)
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return store, nil
+}
+
+func (l *levelDbBackend) get(key []byte) ([]byte, error) {
+	value, err := l.db.Get(key, nil)
+	if err == leveldb.ErrNotFound {
+		return nil, ErrNotFound
+	}
+	return value, err
+}
+
+func (l *levelDbBackend) has(key []byte) (bool, error) {
+	return l.db.Has(key, nil)
+}
+
+func (l *levelDbBackend) write(ops []batchOp) error {
+	batch := new(leveldb.Batch)
+	for _, op := range ops {
+		if op.delete {
+			batch.Delete(op.key)
+		} else {
+			batch.Put(op.key, op.value)
+		}
+	}
+	return l.db.Write(batch, nil)
+}
+
+func (l *levelDbBackend) iterate(start []byte, prefix []byte, fn func(key, value []byte) bool) error {
+	r := util.BytesPrefix(prefix)
+	r.Start = start
+	iter := l.db.NewIterator(r, nil)
+	defer iter.Release()
+
+	for iter.Next() {
+		if !fn(iter.Key(), iter.Value()) {
+			break
+		}
+	}
+
+	return iter.Error()
+}
+
+func (l *levelDbBackend) close() error {
+	return l.db.Close()
+}
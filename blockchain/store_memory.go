@@ -0,0 +1,78 @@
+package blockchain
+
+import (
+	"bytes"
+	"sort"
+	"sync"
+)
+
+type memoryBackend struct {
+	mu   sync.RWMutex
+	data map[string][]byte
+}
+
+// NewMemoryStore returns a Store that keeps everything in memory. It is
+// meant for tests and throwaway nodes.
+func NewMemoryStore() Store {
+	return &kvStore{&memoryBackend{data: map[string][]byte{}}}
+}
+
+func (m *memoryBackend) get(key []byte) ([]byte, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	value, ok := m.data[string(key)]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return append([]byte{}, value...), nil
+}
+
+func (m *memoryBackend) has(key []byte) (bool, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	_, ok := m.data[string(key)]
+	return ok, nil
+}
+
+func (m *memoryBackend) write(ops []batchOp) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, op := range ops {
+		if op.delete {
+			delete(m.data, string(op.key))
+		} else {
+			m.data[string(op.key)] = append([]byte{}, op.value...)
+		}
+	}
+	return nil
+}
+
+func (m *memoryBackend) iterate(start []byte, prefix []byte, fn func(key, value []byte) bool) error {
+	m.mu.RLock()
+	keys := []string{}
+	for key := range m.data {
+		if bytes.HasPrefix([]byte(key), prefix) && key >= string(start) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	values := make([][]byte, len(keys))
+	for i, key := range keys {
+		values[i] = m.data[key]
+	}
+	m.mu.RUnlock()
+
+	for i, key := range keys {
+		if !fn([]byte(key), values[i]) {
+			break
+		}
+	}
+	return nil
+}
+
+func (m *memoryBackend) close() error {
+	return nil
+}
@@ -0,0 +1,475 @@
+package blockchain
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/sap200/evochain/addrbook"
+	"github.com/sap200/evochain/constants"
+)
+
+type ChainTip struct {
+	BlockNumber uint64 `json:"block_number"`
+	BlockHash   string `json:"block_hash"`
+	TotalWork   string `json:"total_work"`
+	GenesisHash string `json:"genesis_hash"`
+	ChainId     uint64 `json:"chain_id"`
+}
+
+var syncClient = &http.Client{Timeout: constants.SYNC_REQUEST_TIMEOUT * time.Second}
+
+func getJson(ourURL string, v interface{}) error {
+	resp, err := syncClient.Get(ourURL)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s returned %s: %s", ourURL, resp.Status, string(data))
+	}
+
+	return json.Unmarshal(data, v)
+}
+
+func FetchTip(address string) (*ChainTip, error) {
+	var tip ChainTip
+	err := getJson(fmt.Sprintf("%s/tip", address), &tip)
+	if err != nil {
+		return nil, err
+	}
+	return &tip, nil
+}
+
+// FetchGenesis returns the genesis of the node at address, checked against
+// the genesis hash it reports.
+func FetchGenesis(address string) (*Genesis, error) {
+	var g Genesis
+	err := getJson(fmt.Sprintf("%s/genesis", address), &g)
+	if err != nil {
+		return nil, err
+	}
+
+	tip, err := FetchTip(address)
+	if err != nil {
+		return nil, err
+	}
+	if g.Block().Hash() != tip.GenesisHash {
+		return nil, fmt.Errorf("genesis of %s does not match its genesis block %s", address, tip.GenesisHash)
+	}
+
+	err = g.Validate()
+	if err != nil {
+		return nil, fmt.Errorf("genesis of %s is invalid: %s", address, err.Error())
+	}
+	return &g, nil
+}
+
+func FetchHeaders(address string, from uint64, count uint64) ([]BlockHeader, error) {
+	params := url.Values{}
+	params.Add("from", strconv.FormatUint(from, 10))
+	params.Add("count", strconv.FormatUint(count, 10))
+
+	headers := []BlockHeader{}
+	err := getJson(fmt.Sprintf("%s/headers?%s", address, params.Encode()), &headers)
+	if err != nil {
+		return nil, err
+	}
+	return headers, nil
+}
+
+func FetchBlockByHash(address string, hash string) (*Block, error) {
+	params := url.Values{}
+	params.Add("hash", hash)
+
+	var b Block
+	err := getJson(fmt.Sprintf("%s/block?%s", address, params.Encode()), &b)
+	if err != nil {
+		return nil, err
+	}
+
+	if b.Hash() != hash {
+		return nil, fmt.Errorf("peer %s sent block %s for %s", address, b.Hash(), hash)
+	}
+	return &b, nil
+}
+
+func FetchBlockByNumber(address string, number uint64) (*Block, error) {
+	params := url.Values{}
+	params.Add("number", strconv.FormatUint(number, 10))
+
+	var b Block
+	err := getJson(fmt.Sprintf("%s/block?%s", address, params.Encode()), &b)
+	if err != nil {
+		return nil, err
+	}
+	return &b, nil
+}
+
+// GetTip returns our tip with the total work of our chain.
+func (bc *BlockchainStruct) GetTip() (*ChainTip, error) {
+	bc.mu.RLock()
+	defer bc.mu.RUnlock()
+
+	tip := bc.tip
+	totalWork, err := GetTotalWorkFromDb(bc.Store, tip.Hash())
+	if err != nil {
+		return nil, err
+	}
+
+	return &ChainTip{tip.BlockNumber, tip.Hash(), totalWork.String(), bc.GenesisHash(), bc.Params.ChainId}, nil
+}
+
+// GetHeaders returns up to count headers of our chain starting at from.
+func (bc *BlockchainStruct) GetHeaders(from uint64, count uint64) ([]BlockHeader, error) {
+	if count > constants.MAX_HEADERS_PER_REQUEST {
+		count = constants.MAX_HEADERS_PER_REQUEST
+	}
+
+	bc.mu.RLock()
+	defer bc.mu.RUnlock()
+
+	headers := []BlockHeader{}
+	if count == 0 {
+		return headers, nil
+	}
+	err := bc.Store.IterateBlocks(from, func(b *Block) bool {
+		headers = append(headers, b.BlockHeader)
+		return uint64(len(headers)) < count
+	})
+	if err != nil {
+		return nil, err
+	}
+	return headers, nil
+}
+
+// activePeers lists the peers holding a slot in our address book.
+func (bc *BlockchainStruct) activePeers() []string {
+	return bc.Peers.Active()
+}
+
+func syncHeaderKey(number uint64) []byte {
+	return append([]byte(constants.SYNC_HEADER_KEY_PREFIX), encodeUint64(number)...)
+}
+
+// loadSyncHeaders returns the headers left to download, in chain order, and
+// the peer whose chain they are of.
+func loadSyncHeaders(store Store) ([]BlockHeader, string, error) {
+	headers := []BlockHeader{}
+	var innerErr error
+	err := store.IteratePrefix([]byte(constants.SYNC_HEADER_KEY_PREFIX), func(key, value []byte) bool {
+		var header BlockHeader
+		innerErr = json.Unmarshal(value, &header)
+		headers = append(headers, header)
+		return innerErr == nil
+	})
+	if err != nil {
+		return nil, "", err
+	}
+	if innerErr != nil {
+		return nil, "", innerErr
+	}
+
+	peer, err := store.Get([]byte(constants.SYNC_PEER_KEY))
+	if err != nil && err != ErrNotFound {
+		return nil, "", err
+	}
+	return headers, string(peer), nil
+}
+
+// saveSyncHeaders stores every header under its own key, so the download
+// only has to delete the headers of each window it applies.
+func saveSyncHeaders(store Store, peer string, headers []BlockHeader) error {
+	batch := store.NewBatch()
+	for _, header := range headers {
+		batch.PutJson(syncHeaderKey(header.BlockNumber), header)
+	}
+	batch.Put([]byte(constants.SYNC_PEER_KEY), []byte(peer))
+	return store.Write(batch)
+}
+
+// dropSyncHeaders deletes the headers left to download, so the next sync
+// starts over from the best peer.
+func dropSyncHeaders(store Store) error {
+	batch := store.NewBatch()
+	err := store.IteratePrefix([]byte(constants.SYNC_HEADER_KEY_PREFIX), func(key, value []byte) bool {
+		batch.Delete(append([]byte{}, key...))
+		return true
+	})
+	if err != nil {
+		return err
+	}
+	batch.Delete([]byte(constants.SYNC_PEER_KEY))
+	return store.Write(batch)
+}
+
+// SyncFromPeers catches our chain up with the peer holding the most work.
+//
+// Headers are fetched first in ranges and their proof of work chain is
+// validated. The validated headers are persisted, then the block bodies are
+// downloaded in windows, in parallel from every active peer, validated and
+// applied. A sync that is interrupted picks up the remaining headers the
+// next time it runs.
+func (bc *BlockchainStruct) SyncFromPeers() error {
+	// the sync already running catches us up as well
+	if !bc.syncMu.TryLock() {
+		return nil
+	}
+	defer bc.syncMu.Unlock()
+
+	peers := bc.activePeers()
+	if len(peers) == 0 {
+		return nil
+	}
+
+	headers, syncPeer, err := loadSyncHeaders(bc.Store)
+	if err != nil {
+		return err
+	}
+
+	if len(headers) > 0 {
+		log.Println("Resuming sync with", len(headers), "headers of", syncPeer, "left to download")
+	} else {
+		bestPeer, peerTip, err := bc.bestPeer(peers)
+		if err != nil || bestPeer == "" {
+			return err
+		}
+
+		headers, err = bc.downloadHeaders(bestPeer, peerTip)
+		if err != nil {
+			return err
+		}
+
+		syncPeer = bestPeer
+		err = saveSyncHeaders(bc.Store, syncPeer, headers)
+		if err != nil {
+			return err
+		}
+	}
+
+	return bc.downloadBlocks(peers, headers)
+}
+
+// bestPeer returns the peer whose chain has more work than ours and than
+// every other peer, if any.
+func (bc *BlockchainStruct) bestPeer(peers []string) (string, *ChainTip, error) {
+	tip, err := bc.GetTip()
+	if err != nil {
+		return "", nil, err
+	}
+	bestWork, _ := new(big.Int).SetString(tip.TotalWork, 10)
+
+	bestPeer := ""
+	var bestTip *ChainTip
+	for _, peer := range peers {
+		peerTip, err := FetchTip(peer)
+		if err != nil {
+			log.Println("Error while fetching the tip of peer:", peer, "Error:", err.Error())
+			continue
+		}
+		if peerTip.GenesisHash != tip.GenesisHash {
+			log.Println("Ignoring peer on another network:", peer, "Genesis:", peerTip.GenesisHash)
+			continue
+		}
+
+		peerWork, ok := new(big.Int).SetString(peerTip.TotalWork, 10)
+		if ok && peerWork.Cmp(bestWork) > 0 {
+			bestWork = peerWork
+			bestPeer = peer
+			bestTip = peerTip
+		}
+	}
+
+	return bestPeer, bestTip, nil
+}
+
+// downloadHeaders fetches and validates the headers of peer's chain after
+// the last block we have in common with it.
+func (bc *BlockchainStruct) downloadHeaders(peer string, peerTip *ChainTip) ([]BlockHeader, error) {
+	log.Println("Downloading headers from peer:", peer)
+
+	// walk back from the lower of both tips until the peer's headers connect
+	// to a block we know
+	bc.mu.RLock()
+	height := bc.tip.BlockNumber
+	bc.mu.RUnlock()
+	if peerTip.BlockNumber < height {
+		height = peerTip.BlockNumber
+	}
+	back := uint64(0)
+	var batch []BlockHeader
+	for {
+		from := uint64(1)
+		if height > back+1 {
+			from = height - back
+		}
+
+		var err error
+		batch, err = FetchHeaders(peer, from, constants.MAX_HEADERS_PER_REQUEST)
+		if err != nil {
+			return nil, err
+		}
+		if len(batch) == 0 {
+			return []BlockHeader{}, nil
+		}
+
+		if HasBlockInDb(bc.Store, batch[0].PrevHash) {
+			break
+		}
+		if from == 1 {
+			return nil, errors.New("peer chain does not share our genesis block")
+		}
+		back = back*2 + constants.FETCH_LAST_N_BLOCKS
+	}
+
+	// skip the headers of blocks we already have
+	for len(batch) > 0 && HasBlockInDb(bc.Store, batch[0].Hash()) {
+		batch = batch[1:]
+	}
+	if len(batch) == 0 {
+		return []BlockHeader{}, nil
+	}
+
+	bc.mu.RLock()
+	ancestors, _, _, err := bc.ancestorsOf(batch[0].PrevHash)
+	bc.mu.RUnlock()
+	if err != nil {
+		return nil, err
+	}
+
+	headers := []BlockHeader{}
+	for len(batch) > 0 {
+		for _, header := range batch {
+			parent := ancestors[len(ancestors)-1]
+			target, err := NextTarget(bc.Params, ancestors)
+			if err == nil {
+				err = ValidateHeader(parent, header, target)
+			}
+			if err != nil {
+				return nil, err
+			}
+
+			ancestors = append(ancestors, header)
+			if uint64(len(ancestors)) > bc.Params.RetargetWindow+1 {
+				ancestors = ancestors[1:]
+			}
+			headers = append(headers, header)
+		}
+
+		log.Println("Validated headers up to block", headers[len(headers)-1].BlockNumber)
+		if uint64(len(batch)) < constants.MAX_HEADERS_PER_REQUEST {
+			break
+		}
+
+		var err error
+		batch, err = FetchHeaders(peer, headers[len(headers)-1].BlockNumber+1, constants.MAX_HEADERS_PER_REQUEST)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return headers, nil
+}
+
+// downloadBlocks fetches the bodies of headers window by window. Within a
+// window, blocks are spread over peers and fetched by a bounded number of
+// workers; the window is then applied in order through ProcessBlocks. When a
+// block cannot be downloaded or applied, the headers left are dropped, and
+// the peer that served an invalid block is charged for it. Any peer can serve
+// a body matching its header with other signatures, so it is not necessarily
+// the peer whose chain the headers are of.
+func (bc *BlockchainStruct) downloadBlocks(peers []string, headers []BlockHeader) error {
+	for len(headers) > 0 {
+		window := headers
+		if len(window) > constants.SYNC_WINDOW {
+			window = window[:constants.SYNC_WINDOW]
+		}
+
+		blocks := make([]*Block, len(window))
+		servedBy := make([]string, len(window))
+		errs := make([]error, len(window))
+		jobs := make(chan int)
+		var wg sync.WaitGroup
+		for w := 0; w < constants.SYNC_WORKERS; w++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for i := range jobs {
+					// try every peer, starting with a different one per block
+					for j := 0; j < len(peers); j++ {
+						peer := peers[(i+j)%len(peers)]
+						blocks[i], errs[i] = FetchBlockByHash(peer, window[i].Hash())
+						if errs[i] == nil {
+							servedBy[i] = peer
+							break
+						}
+					}
+				}
+			}()
+		}
+		for i := range window {
+			jobs <- i
+		}
+		close(jobs)
+		wg.Wait()
+
+		for i, err := range errs {
+			if err != nil {
+				return bc.abortSync(fmt.Errorf("could not download block %d: %s", window[i].BlockNumber, err.Error()))
+			}
+		}
+
+		err := bc.ProcessBlocks(blocks)
+		if errors.Is(err, ErrInvalidBlock) {
+			// the blocks before the invalid one are stored
+			for i, b := range blocks {
+				if !HasBlockInDb(bc.Store, b.Hash()) {
+					bc.PeerMisbehaved(addrbook.HostOf(servedBy[i]), constants.INVALID_BLOCK_SCORE, err.Error())
+					break
+				}
+			}
+		}
+		if err != nil {
+			return bc.abortSync(err)
+		}
+
+		batch := bc.Store.NewBatch()
+		for _, header := range window {
+			batch.Delete(syncHeaderKey(header.BlockNumber))
+		}
+		if len(window) == len(headers) {
+			batch.Delete([]byte(constants.SYNC_PEER_KEY))
+		}
+		err = bc.Store.Write(batch)
+		if err != nil {
+			return err
+		}
+		headers = headers[len(window):]
+		log.Println("Synced up to block", window[len(window)-1].BlockNumber)
+	}
+
+	return nil
+}
+
+// abortSync drops the headers left to download and returns err.
+func (bc *BlockchainStruct) abortSync(err error) error {
+	dropErr := dropSyncHeaders(bc.Store)
+	if dropErr != nil {
+		return dropErr
+	}
+	return err
+}
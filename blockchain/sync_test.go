@@ -0,0 +1,146 @@
+package blockchain
+
+import (
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/sap200/evochain/constants"
+)
+
+// servePeer serves the blocks of source by hash from host, passing each
+// through tamper first.
+func servePeer(t *testing.T, host string, source *BlockchainStruct, tamper func(b *Block)) *httptest.Server {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", net.JoinHostPort(host, "0"))
+	if err != nil {
+		t.Skip("cannot listen on", host, err)
+	}
+	peer := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		b, err := source.Store.GetBlock(req.URL.Query().Get("hash"))
+		if err != nil {
+			http.NotFound(w, req)
+			return
+		}
+		tamper(b)
+		json.NewEncoder(w).Encode(b)
+	}))
+	peer.Listener.Close()
+	peer.Listener = listener
+	peer.Start()
+	t.Cleanup(peer.Close)
+	return peer
+}
+
+func TestDownloadBlocks(t *testing.T) {
+	sender := newTestKey(t)
+	miner := newTestKey(t)
+	alloc := map[string]uint64{sender.address: 1000}
+	source := newTestChain(t, alloc)
+	for nonce := uint64(0); nonce < 3; nonce++ {
+		txn := sender.transfer(t, source.Params, miner.address, 10, 1, nonce)
+		err := source.ProcessBlocks([]*Block{mineOn(source.Params, canonicalChain(t, source), miner.address, txn)})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	headers := []BlockHeader{}
+	for _, b := range canonicalChain(t, source)[1:] {
+		headers = append(headers, b.BlockHeader)
+	}
+
+	t.Run("invalid block", func(t *testing.T) {
+		bc := newTestChain(t, alloc)
+		syncPeer := servePeer(t, "127.0.0.1", source, func(b *Block) {})
+		// serves block 2, the second of the window, with the header and
+		// transaction hashes right but a signature that is not
+		liar := servePeer(t, "127.0.0.2", source, func(b *Block) {
+			if b.BlockNumber == 2 {
+				b.Transactions[0].Signature = []byte{0}
+			}
+		})
+		err := saveSyncHeaders(bc.Store, syncPeer.URL, headers)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		err = bc.downloadBlocks([]string{syncPeer.URL, liar.URL}, headers)
+		if err == nil {
+			t.Fatal("downloadBlocks() accepted an invalid block")
+		}
+		left, leftPeer, err := loadSyncHeaders(bc.Store)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(left) != 0 || leftPeer != "" {
+			t.Fatalf("kept %d sync headers of %q after an invalid block", len(left), leftPeer)
+		}
+		if !bc.Peers.Banned("127.0.0.2") {
+			t.Fatal("the peer serving the invalid block was not charged")
+		}
+		if bc.Peers.Banned("127.0.0.1") {
+			t.Fatal("the sync peer was charged for a block another peer served")
+		}
+	})
+
+	t.Run("valid blocks", func(t *testing.T) {
+		bc := newTestChain(t, alloc)
+		peer := servePeer(t, "127.0.0.1", source, func(b *Block) {})
+		err := saveSyncHeaders(bc.Store, peer.URL, headers)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		err = bc.downloadBlocks([]string{peer.URL}, headers)
+		if err != nil {
+			t.Fatalf("downloadBlocks() error = %v", err)
+		}
+		if tip := bc.tip; tip.Hash() != source.tip.Hash() {
+			t.Fatalf("synced to block %d, want the tip of the peer", tip.BlockNumber)
+		}
+		left, syncPeer, err := loadSyncHeaders(bc.Store)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(left) != 0 || syncPeer != "" {
+			t.Fatalf("kept %d sync headers of %q after downloading them", len(left), syncPeer)
+		}
+	})
+}
+
+func TestSyncHeadersKeepChainOrder(t *testing.T) {
+	store := NewMemoryStore()
+	headers := []BlockHeader{}
+	for _, number := range []uint64{255, 256, 257, 65536} {
+		headers = append(headers, BlockHeader{BlockNumber: number})
+	}
+
+	err := saveSyncHeaders(store, "http://127.0.0.1:5000", headers)
+	if err != nil {
+		t.Fatal(err)
+	}
+	loaded, _, err := loadSyncHeaders(store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(loaded) != len(headers) {
+		t.Fatalf("loaded %d headers, want %d", len(loaded), len(headers))
+	}
+	for i := range headers {
+		if loaded[i].BlockNumber != headers[i].BlockNumber {
+			t.Fatalf("header %d is block %d, want %d", i, loaded[i].BlockNumber, headers[i].BlockNumber)
+		}
+	}
+
+	err = dropSyncHeaders(store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	has, err := store.Has([]byte(constants.SYNC_PEER_KEY))
+	if err != nil || has {
+		t.Fatalf("sync peer kept after dropping the sync headers: %v", err)
+	}
+}
@@ -2,14 +2,13 @@ package blockchain
 
 import (
 	"crypto/ecdsa"
-	"crypto/elliptic"
 	"crypto/sha256"
 	"encoding/hex"
 	"encoding/json"
-	"math"
-	"math/big"
 	"time"
 
+	"github.com/sap200/evochain/address"
+	"github.com/sap200/evochain/codec"
 	"github.com/sap200/evochain/constants"
 )
 
@@ -17,6 +16,9 @@ type Transaction struct {
 	From            string `json:"from"`
 	To              string `json:"to"`
 	Value           uint64 `json:"value"`
+	Fee             uint64 `json:"fee,omitempty"`
+	ChainId         uint64 `json:"chain_id,omitempty"` // network the sender signed for, none on coinbases
+	Nonce           uint64 `json:"nonce"`
 	Data            []byte `json:"data"`
 	Status          string `json:"status"`
 	Timestamp       int64  `json:"timestamp"`
@@ -25,11 +27,13 @@ type Transaction struct {
 	Signature       []byte `json:"Signature"`
 }
 
-func NewTransaction(from, to string, value uint64, data []byte) *Transaction {
+func NewTransaction(from, to string, value uint64, fee uint64, nonce uint64, data []byte) *Transaction {
 	t := new(Transaction)
 	t.From = from
 	t.To = to
 	t.Value = value
+	t.Fee = fee
+	t.Nonce = nonce
 	t.Data = data
 	t.Timestamp = time.Now().UnixNano()
 	t.Status = constants.PENDING
@@ -49,74 +53,77 @@ func (t Transaction) ToJson() string {
 	}
 }
 
-func (t Transaction) VerifyTxn() bool {
-	if t.Value <= 0 {
-		return false
-	}
+// Size is the number of bytes the transaction takes in a block.
+func (t Transaction) Size() int {
+	nb, _ := json.Marshal(t)
+	return len(nb)
+}
 
-	if t.Value > math.MaxUint64 {
-		return false
-	}
+// GetHash, GetSender, GetNonce and GetFee let the mempool index transactions.
+func (t *Transaction) GetHash() string {
+	return t.TransactionHash
+}
 
-	if t.From == t.To {
-		return false
-	}
+func (t *Transaction) GetSender() string {
+	return t.From
+}
 
-	valid := t.VerifySignature()
-	if !valid {
-		return false
-	}
+func (t *Transaction) GetNonce() uint64 {
+	return t.Nonce
+}
 
-	return true
+func (t *Transaction) GetFee() uint64 {
+	return t.Fee
 }
 
-func (t *Transaction) VerifySignature() bool {
+// VerifySignature reports whether the sender signed the transaction for the
+// chain chainId, with the key its address is derived from.
+func (t *Transaction) VerifySignature(chainId uint64) bool {
 
-	if t.Signature == nil {
+	if t.Signature == nil || t.ChainId != chainId {
 		return false
 	}
 
-	if t.PublicKey == "" {
+	if address.FromPublicKeyHex(t.PublicKey) != t.From {
 		return false
 	}
 
-	signature := t.Signature
-	publicKeyHex := t.PublicKey
-	t.Signature = []byte{}
-	t.PublicKey = ""
-	publicKeyEcdsa := GetPublicKeyFromHex(publicKeyHex)
+	publicKeyEcdsa, err := address.ParsePublicKeyHex(t.PublicKey)
+	if err != nil {
+		return false
+	}
+	hash := t.SigningHash()
 
-	bs, _ := json.Marshal(t)
-	hash := sha256.Sum256(bs)
+	return ecdsa.VerifyASN1(publicKeyEcdsa, hash[:], t.Signature)
+}
 
-	valid := ecdsa.VerifyASN1(publicKeyEcdsa, hash[:], signature)
-	t.Signature = signature
-	return valid
+// Encode is the canonical encoding of the fields the sender chooses. The
+// fields the node sets or that carry the signature are left out.
+func (t Transaction) Encode() []byte {
+	e := codec.NewEncoder(constants.TXN_ENCODING_VERSION)
+	e.PutUint64(t.ChainId)
+	e.PutString(t.From)
+	e.PutString(t.To)
+	e.PutUint64(t.Value)
+	e.PutUint64(t.Fee)
+	e.PutUint64(t.Nonce)
+	e.PutBytes(t.Data)
+	e.PutInt64(t.Timestamp)
+	return e.Bytes()
 }
 
-func (t Transaction) Hash() string {
+// SigningHash is the digest the sender signs. The domain prefix keeps it
+// from being the hash of anything else.
+func (t Transaction) SigningHash() [32]byte {
+	return sha256.Sum256(append([]byte(constants.TXN_SIGNING_DOMAIN), t.Encode()...))
+}
 
-	bs, _ := json.Marshal(t)
-	sum := sha256.Sum256(bs)
+// Hash of a transaction is the hash of its canonical encoding, so it does
+// not change with the status, public key or signature.
+func (t Transaction) Hash() string {
+	sum := sha256.Sum256(t.Encode())
 	hexRep := hex.EncodeToString(sum[:32])
 	formattedHexRep := constants.HEX_PREFIX + hexRep
 
 	return formattedHexRep
 }
-
-func GetPublicKeyFromHex(publicKeyHex string) *ecdsa.PublicKey {
-	rpk := publicKeyHex[2:]
-	xHex := rpk[:64]
-	yHex := rpk[64:]
-	x := new(big.Int)
-	y := new(big.Int)
-	x.SetString(xHex, 16)
-	y.SetString(yHex, 16)
-
-	var npk ecdsa.PublicKey
-	npk.Curve = elliptic.P256()
-	npk.X = x
-	npk.Y = y
-
-	return &npk
-}
@@ -0,0 +1,131 @@
+package blockchain
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/sap200/evochain/address"
+	"github.com/sap200/evochain/constants"
+)
+
+var (
+	ErrTxnBadHash           = errors.New("transaction hash does not match its content")
+	ErrTxnZeroValue         = errors.New("transaction value must be greater than zero")
+	ErrTxnSelfTransfer      = errors.New("transaction sender and receiver are the same")
+	ErrTxnBadRecipient      = errors.New("transaction receiver is not an address")
+	ErrTxnBadSignature      = errors.New("transaction signature is invalid")
+	ErrTxnWrongChain        = errors.New("transaction is signed for another chain")
+	ErrTxnWrongSender       = errors.New("transaction public key does not belong to the sender address")
+	ErrTxnBadNonce          = errors.New("transaction nonce is not the next nonce of the sender")
+	ErrTxnInsufficientFunds = errors.New("sender balance is too low for the transaction")
+)
+
+// IsMalformedTxn reports whether err refuses a transaction that is invalid
+// whatever the state it is checked against.
+func IsMalformedTxn(err error) bool {
+	switch err {
+	case ErrTxnBadHash, ErrTxnZeroValue, ErrTxnSelfTransfer, ErrTxnBadRecipient, ErrTxnBadSignature, ErrTxnWrongChain, ErrTxnWrongSender:
+		return true
+	}
+	return false
+}
+
+// ValidateHeader checks that header follows parent: the current version,
+// contiguous number, link to the parent hash, a timestamp after the parent and not too far in
+// the future, the expected target and a proof of work meeting it.
+func ValidateHeader(parent BlockHeader, header BlockHeader, expectedTarget string) error {
+	if header.Version != constants.BLOCK_VERSION {
+		return fmt.Errorf("block %d has unsupported version %d", header.BlockNumber, header.Version)
+	}
+
+	if header.BlockNumber != parent.BlockNumber+1 {
+		return fmt.Errorf("block number %d does not follow parent %d", header.BlockNumber, parent.BlockNumber)
+	}
+
+	if header.PrevHash != parent.Hash() {
+		return fmt.Errorf("block %d does not link to its parent %s", header.BlockNumber, parent.Hash())
+	}
+
+	if header.Timestamp <= parent.Timestamp {
+		return fmt.Errorf("block %d timestamp is not after its parent", header.BlockNumber)
+	}
+
+	if header.Timestamp > time.Now().Add(constants.MAX_FUTURE_BLOCK_TIME*time.Second).UnixNano() {
+		return fmt.Errorf("block %d timestamp is too far in the future", header.BlockNumber)
+	}
+
+	if header.Target != expectedTarget {
+		return fmt.Errorf("block %d has target %s, expected %s", header.BlockNumber, header.Target, expectedTarget)
+	}
+
+	if !checkProofOfWork(header) {
+		return fmt.Errorf("block %d hash %s does not meet its target", header.BlockNumber, header.Hash())
+	}
+
+	return nil
+}
+
+// ValidateBlock checks that block can be appended on top of parentState: its
+// header must be valid on top of the state's parent, the merkle root must
+// commit to its transactions, every transaction, the coinbase included, must
+// match its hash, it must hold exactly one coinbase paying the block reward
+// of the emission schedule plus the fees of the block, and every other
+// transaction must be valid when executed in order against the state.
+func ValidateBlock(params *ChainParams, parentState *AccountState, block *Block, expectedTarget string) error {
+	err := ValidateHeader(parentState.Parent.BlockHeader, block.BlockHeader, expectedTarget)
+	if err != nil {
+		return err
+	}
+
+	if block.MerkleRoot != block.ComputeMerkleRoot() {
+		return fmt.Errorf("block %d merkle root does not match its transactions", block.BlockNumber)
+	}
+
+	fees := uint64(0)
+	for _, txn := range block.Transactions {
+		if txn.From != constants.BLOCKCHAIN_ADDRESS {
+			fees += txn.Fee
+		}
+	}
+
+	state := parentState.Copy()
+	coinbases := 0
+	seen := map[string]bool{}
+	for _, txn := range block.Transactions {
+		if seen[txn.TransactionHash] {
+			return fmt.Errorf("transaction %s appears twice in block %d", txn.TransactionHash, block.BlockNumber)
+		}
+		seen[txn.TransactionHash] = true
+
+		if txn.TransactionHash != txn.Hash() {
+			return fmt.Errorf("transaction %s in block %d does not match its hash", txn.TransactionHash, block.BlockNumber)
+		}
+
+		if txn.From == constants.BLOCKCHAIN_ADDRESS {
+			coinbases++
+			if txn.Value != params.BlockReward(block.BlockNumber)+fees || txn.Fee != 0 || txn.Status != constants.SUCCESS || !address.IsValid(txn.To) {
+				return fmt.Errorf("block %d has an invalid coinbase transaction", block.BlockNumber)
+			}
+			state.ApplyTransaction(txn)
+			continue
+		}
+
+		if txn.Status != constants.SUCCESS {
+			return fmt.Errorf("transaction %s in block %d has status %s", txn.TransactionHash, block.BlockNumber, txn.Status)
+		}
+
+		err := state.CheckTransaction(txn, params.ChainId)
+		if err != nil {
+			return fmt.Errorf("transaction %s in block %d is invalid: %s", txn.TransactionHash, block.BlockNumber, err.Error())
+		}
+
+		state.ApplyTransaction(txn)
+	}
+
+	if coinbases != 1 {
+		return fmt.Errorf("block %d has %d coinbase transactions", block.BlockNumber, coinbases)
+	}
+
+	return nil
+}
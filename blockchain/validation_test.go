@@ -0,0 +1,199 @@
+package blockchain
+
+import (
+	"errors"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/sap200/evochain/constants"
+)
+
+func TestProcessBlocksRejectsTamperedCoinbase(t *testing.T) {
+	bc := newTestChain(t, nil)
+	honest := newTestKey(t)
+	thief := newTestKey(t)
+
+	mined := mineOn(bc.Params, canonicalChain(t, bc), honest.address)
+
+	tests := []struct {
+		name   string
+		tamper func(coinbase *Transaction)
+	}{
+		{"claimed hash kept", func(coinbase *Transaction) {
+			coinbase.To = thief.address
+		}},
+		{"hash recomputed", func(coinbase *Transaction) {
+			coinbase.To = thief.address
+			coinbase.TransactionHash = coinbase.Hash()
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			relayed := *mined
+			coinbase := *mined.Transactions[len(mined.Transactions)-1]
+			tt.tamper(&coinbase)
+			relayed.Transactions = []*Transaction{&coinbase}
+
+			err := bc.ProcessBlocks([]*Block{&relayed})
+			if !errors.Is(err, ErrInvalidBlock) {
+				t.Fatalf("ProcessBlocks() error = %v, want ErrInvalidBlock", err)
+			}
+			if balance := bc.CalculateTotalCrypto(thief.address); balance != 0 {
+				t.Fatalf("thief balance = %d, want 0", balance)
+			}
+		})
+	}
+
+	err := bc.ProcessBlocks([]*Block{mined})
+	if err != nil {
+		t.Fatalf("ProcessBlocks() of the honest block error = %v", err)
+	}
+	if balance := bc.CalculateTotalCrypto(honest.address); balance != bc.Params.BlockReward(1) {
+		t.Fatalf("honest balance = %d, want %d", balance, bc.Params.BlockReward(1))
+	}
+}
+
+func TestValidateBlockRejectsTransactionNotMatchingItsHash(t *testing.T) {
+	sender := newTestKey(t)
+	bc := newTestChain(t, map[string]uint64{sender.address: 1000})
+
+	txn := sender.transfer(t, bc.Params, newTestKey(t).address, 10, 1, 0)
+	b := nextBlock(bc.Params, canonicalChain(t, bc), sender.address, txn)
+	b.Transactions[0].Value = 900
+	b.MerkleRoot = b.ComputeMerkleRoot()
+	solve(b)
+
+	target, err := NextTarget(bc.Params, bc.headers)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = ValidateBlock(bc.Params, bc.tipState(), b, target)
+	if err == nil {
+		t.Fatal("ValidateBlock() accepted a transaction whose hash does not match its content")
+	}
+}
+
+func TestMerkleRootCommitsToTransactions(t *testing.T) {
+	sender := newTestKey(t)
+	receiver := newTestKey(t)
+	bc := newTestChain(t, map[string]uint64{sender.address: 1000})
+
+	b := nextBlock(bc.Params, canonicalChain(t, bc), receiver.address, sender.transfer(t, bc.Params, receiver.address, 10, 1, 0))
+	solve(b)
+	target, err := NextTarget(bc.Params, bc.headers)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = ValidateBlock(bc.Params, bc.tipState(), b, target)
+	if err != nil {
+		t.Fatalf("ValidateBlock() error = %v", err)
+	}
+
+	// a valid transaction swapped in keeps the header and its proof of work
+	hash := b.Hash()
+	b.Transactions[0] = sender.transfer(t, bc.Params, receiver.address, 20, 1, 0)
+	if b.Hash() != hash {
+		t.Fatal("the block hash covers more than the header")
+	}
+	err = ValidateBlock(bc.Params, bc.tipState(), b, target)
+	if err == nil {
+		t.Fatal("ValidateBlock() accepted transactions not matching the merkle root")
+	}
+}
+
+func TestValidateBlock(t *testing.T) {
+	sender := newTestKey(t)
+	receiver := newTestKey(t)
+	bc := newTestChain(t, map[string]uint64{sender.address: 1000})
+	target, err := NextTarget(bc.Params, bc.headers)
+	if err != nil {
+		t.Fatal(err)
+	}
+	genesis := canonicalChain(t, bc)[0]
+
+	tests := []struct {
+		name    string
+		txns    func() []*Transaction
+		change  func(b *Block)
+		wantErr bool
+	}{
+		{"valid", nil, func(b *Block) {}, false},
+		{"valid transfers", func() []*Transaction {
+			return []*Transaction{
+				sender.transfer(t, bc.Params, receiver.address, 100, 1, 0),
+				sender.transfer(t, bc.Params, receiver.address, 100, 1, 1),
+			}
+		}, func(b *Block) {}, false},
+		{"bad signature", func() []*Transaction {
+			txn := sender.transfer(t, bc.Params, receiver.address, 100, 1, 0)
+			txn.Signature = newTestKey(t).transfer(t, bc.Params, receiver.address, 100, 1, 0).Signature
+			return []*Transaction{txn}
+		}, func(b *Block) {}, true},
+		{"overspend", func() []*Transaction {
+			return []*Transaction{sender.transfer(t, bc.Params, receiver.address, 1000, 1, 0)}
+		}, func(b *Block) {}, true},
+		{"nonce out of order", func() []*Transaction {
+			return []*Transaction{sender.transfer(t, bc.Params, receiver.address, 100, 1, 1)}
+		}, func(b *Block) {}, true},
+		{"duplicate transaction", func() []*Transaction {
+			txn := sender.transfer(t, bc.Params, receiver.address, 100, 1, 0)
+			return []*Transaction{txn, txn}
+		}, func(b *Block) {}, true},
+		{"failed transaction", func() []*Transaction {
+			return []*Transaction{sender.transfer(t, bc.Params, receiver.address, 100, 1, 0)}
+		}, func(b *Block) {
+			b.Transactions[0].Status = constants.FAILED
+		}, true},
+		{"no coinbase", nil, func(b *Block) {
+			b.Transactions = b.Transactions[:len(b.Transactions)-1]
+		}, true},
+		{"two coinbases", nil, func(b *Block) {
+			second := *b.Transactions[len(b.Transactions)-1]
+			second.To = receiver.address
+			second.TransactionHash = second.Hash()
+			b.Transactions = append(b.Transactions, &second)
+		}, true},
+		{"reward too high", nil, func(b *Block) {
+			coinbase := b.Transactions[len(b.Transactions)-1]
+			coinbase.Value++
+			coinbase.TransactionHash = coinbase.Hash()
+		}, true},
+		{"fees taken twice", func() []*Transaction {
+			return []*Transaction{sender.transfer(t, bc.Params, receiver.address, 100, 50, 0)}
+		}, func(b *Block) {
+			coinbase := b.Transactions[len(b.Transactions)-1]
+			coinbase.Value += 50
+			coinbase.TransactionHash = coinbase.Hash()
+		}, true},
+		{"legacy version", nil, func(b *Block) { b.Version = 0 }, true},
+		{"number skipped", nil, func(b *Block) { b.BlockNumber++ }, true},
+		{"wrong parent", nil, func(b *Block) { b.PrevHash = genesis.PrevHash }, true},
+		{"timestamp of the parent", nil, func(b *Block) { b.Timestamp = genesis.Timestamp }, true},
+		{"timestamp in the future", nil, func(b *Block) {
+			b.Timestamp = time.Now().Add(2 * constants.MAX_FUTURE_BLOCK_TIME * time.Second).UnixNano()
+		}, true},
+		{"easier target", nil, func(b *Block) {
+			b.Target = BigToTarget(new(big.Int).Add(powLimit, big.NewInt(1)))
+		}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			txns := []*Transaction{}
+			if tt.txns != nil {
+				txns = tt.txns()
+			}
+			b := nextBlock(bc.Params, canonicalChain(t, bc), receiver.address, txns...)
+			tt.change(b)
+			b.MerkleRoot = b.ComputeMerkleRoot()
+			if b.Target == target {
+				solve(b)
+			}
+
+			err := ValidateBlock(bc.Params, bc.tipState(), b, target)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ValidateBlock() error = %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
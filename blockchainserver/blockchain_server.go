@@ -5,33 +5,43 @@ import (
 	"io"
 	"io/ioutil"
 	"log"
+	"math"
+	"net"
 	"net/http"
 	"strconv"
 
+	"github.com/sap200/evochain/addrbook"
 	"github.com/sap200/evochain/blockchain"
 	"github.com/sap200/evochain/constants"
+	"github.com/sap200/evochain/mempool"
 )
 
 type BlockchainServer struct {
+	BindAddress   string                       `json:"bind_address"`
 	Port          uint64                       `json:"port"`
+	AdminAddress  string                       `json:"admin_address"` // Host and port of the admin API
 	BlockchainPtr *blockchain.BlockchainStruct `json:"blockchain"`
+	Miner         *blockchain.Miner            `json:"-"`
 }
 
-func NewBlockchainServer(port uint64, blockchainPtr *blockchain.BlockchainStruct) *BlockchainServer {
+func NewBlockchainServer(bindAddress string, port uint64, adminAddress string, blockchainPtr *blockchain.BlockchainStruct, miner *blockchain.Miner) *BlockchainServer {
 	bcs := new(BlockchainServer)
+	bcs.BindAddress = bindAddress
 	bcs.Port = port
+	bcs.AdminAddress = adminAddress
 	bcs.BlockchainPtr = blockchainPtr
+	bcs.Miner = miner
 
 	return bcs
 }
 
-func (bcs *BlockchainServer) GetBlockchain(w http.ResponseWriter, req *http.Request) {
-	w.Header().Add("Content-Type", "application/json")
-	if req.Method == http.MethodGet {
-		io.WriteString(w, bcs.BlockchainPtr.ToJson())
-	} else {
-		http.Error(w, "Invalid Method", http.StatusBadRequest)
+// queryUint64 parses an optional unsigned query parameter.
+func queryUint64(req *http.Request, name string, def uint64) (uint64, error) {
+	value := req.URL.Query().Get(name)
+	if value == "" {
+		return def, nil
 	}
+	return strconv.ParseUint(value, 10, 64)
 }
 
 func (bcs *BlockchainServer) GetBalance(w http.ResponseWriter, req *http.Request) {
@@ -55,21 +65,463 @@ func (bcs *BlockchainServer) GetBalance(w http.ResponseWriter, req *http.Request
 	}
 }
 
-func (bcs *BlockchainServer) GetAllNonRewardedTxns(w http.ResponseWriter, req *http.Request) {
+func (bcs *BlockchainServer) GetNonce(w http.ResponseWriter, req *http.Request) {
+	w.Header().Add("Content-Type", "application/json")
+	if req.Method == http.MethodGet {
+		addr := req.URL.Query().Get("address")
+		x := struct {
+			Nonce uint64 `json:"nonce"`
+		}{
+			bcs.BlockchainPtr.GetNextNonce(addr),
+		}
+
+		mNonce, err := json.Marshal(x)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		io.WriteString(w, string(mNonce))
+	} else {
+		http.Error(w, "Invalid Method", http.StatusBadRequest)
+	}
+}
+
+func (bcs *BlockchainServer) GetMerkleProof(w http.ResponseWriter, req *http.Request) {
+	w.Header().Add("Content-Type", "application/json")
+	if req.Method == http.MethodGet {
+		blockNumber, err := strconv.ParseUint(req.URL.Query().Get("block_number"), 10, 64)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		proof, err := bcs.BlockchainPtr.GetMerkleProof(blockNumber, req.URL.Query().Get("txn_hash"))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+
+		mProof, err := json.Marshal(proof)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		io.WriteString(w, string(mProof))
+	} else {
+		http.Error(w, "Invalid Method", http.StatusBadRequest)
+	}
+}
+
+func (bcs *BlockchainServer) GetEvents(w http.ResponseWriter, req *http.Request) {
+	w.Header().Add("Content-Type", "application/json")
+	if req.Method == http.MethodGet {
+		events, err := json.Marshal(bcs.BlockchainPtr.Events.Recent())
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		io.WriteString(w, string(events))
+	} else {
+		http.Error(w, "Invalid Method", http.StatusBadRequest)
+	}
+}
+
+func (bcs *BlockchainServer) GetTip(w http.ResponseWriter, req *http.Request) {
+	w.Header().Add("Content-Type", "application/json")
+	if req.Method == http.MethodGet {
+		tip, err := bcs.BlockchainPtr.GetTip()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		mTip, err := json.Marshal(tip)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		io.WriteString(w, string(mTip))
+	} else {
+		http.Error(w, "Invalid Method", http.StatusBadRequest)
+	}
+}
+
+func (bcs *BlockchainServer) GetHeaders(w http.ResponseWriter, req *http.Request) {
+	w.Header().Add("Content-Type", "application/json")
+	if req.Method == http.MethodGet {
+		from, err := strconv.ParseUint(req.URL.Query().Get("from"), 10, 64)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		count, err := strconv.ParseUint(req.URL.Query().Get("count"), 10, 64)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		headers, err := bcs.BlockchainPtr.GetHeaders(from, count)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		mHeaders, err := json.Marshal(headers)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		io.WriteString(w, string(mHeaders))
+	} else {
+		http.Error(w, "Invalid Method", http.StatusBadRequest)
+	}
+}
+
+func (bcs *BlockchainServer) GetBlock(w http.ResponseWriter, req *http.Request) {
+	w.Header().Add("Content-Type", "application/json")
+	if req.Method == http.MethodGet {
+		var b *blockchain.Block
+		var err error
+		if hash := req.URL.Query().Get("hash"); hash != "" {
+			b, err = bcs.BlockchainPtr.Store.GetBlock(hash)
+		} else {
+			var number uint64
+			number, err = strconv.ParseUint(req.URL.Query().Get("number"), 10, 64)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			b, err = bcs.BlockchainPtr.Store.GetBlockByNumber(number)
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+
+		io.WriteString(w, b.ToJson())
+	} else {
+		http.Error(w, "Invalid Method", http.StatusBadRequest)
+	}
+}
+
+func (bcs *BlockchainServer) GetBlocks(w http.ResponseWriter, req *http.Request) {
 	w.Header().Add("Content-Type", "application/json")
 	if req.Method == http.MethodGet {
-		txnList := bcs.BlockchainPtr.GetAllTxns()
-		byteSlice, err := json.Marshal(txnList)
+		from, err := queryUint64(req, "from", 0)
 		if err != nil {
 			http.Error(w, err.Error(), http.StatusBadRequest)
 			return
 		}
-		io.WriteString(w, string(byteSlice))
+
+		// the cursor of a page is the number of the first block of the next one
+		from, err = queryUint64(req, "cursor", from)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		to, err := queryUint64(req, "to", math.MaxUint64)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		limit, err := queryUint64(req, "limit", 0)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		page, err := bcs.BlockchainPtr.GetBlocks(from, to, int(limit))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		mPage, err := json.Marshal(page)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		io.WriteString(w, string(mPage))
 	} else {
 		http.Error(w, "Invalid Method", http.StatusBadRequest)
 	}
 }
 
+func (bcs *BlockchainServer) GetLatestBlocks(w http.ResponseWriter, req *http.Request) {
+	w.Header().Add("Content-Type", "application/json")
+	if req.Method == http.MethodGet {
+		count, err := queryUint64(req, "count", 0)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		blocks, err := bcs.BlockchainPtr.GetLatestBlocks(int(count))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		mBlocks, err := json.Marshal(blocks)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		io.WriteString(w, string(mBlocks))
+	} else {
+		http.Error(w, "Invalid Method", http.StatusBadRequest)
+	}
+}
+
+func (bcs *BlockchainServer) GetTransaction(w http.ResponseWriter, req *http.Request) {
+	w.Header().Add("Content-Type", "application/json")
+	if req.Method == http.MethodGet {
+		info, err := bcs.BlockchainPtr.GetTransaction(req.URL.Query().Get("hash"))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+
+		mInfo, err := json.Marshal(info)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		io.WriteString(w, string(mInfo))
+	} else {
+		http.Error(w, "Invalid Method", http.StatusBadRequest)
+	}
+}
+
+func (bcs *BlockchainServer) GetReceipt(w http.ResponseWriter, req *http.Request) {
+	w.Header().Add("Content-Type", "application/json")
+	if req.Method == http.MethodGet {
+		receipt, err := bcs.BlockchainPtr.GetReceipt(req.URL.Query().Get("hash"))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+
+		mReceipt, err := json.Marshal(receipt)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		io.WriteString(w, string(mReceipt))
+	} else {
+		http.Error(w, "Invalid Method", http.StatusBadRequest)
+	}
+}
+
+func (bcs *BlockchainServer) GetPoolTransactions(w http.ResponseWriter, req *http.Request) {
+	w.Header().Add("Content-Type", "application/json")
+	if req.Method == http.MethodGet {
+		minFee, err := queryUint64(req, "min_fee", 0)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		cursor, err := queryUint64(req, "cursor", 0)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		limit, err := queryUint64(req, "limit", 0)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		page := bcs.BlockchainPtr.GetPoolTransactions(req.URL.Query().Get("sender"), minFee, cursor, int(limit))
+		mPage, err := json.Marshal(page)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		io.WriteString(w, string(mPage))
+	} else {
+		http.Error(w, "Invalid Method", http.StatusBadRequest)
+	}
+}
+
+func (bcs *BlockchainServer) GetPoolStats(w http.ResponseWriter, req *http.Request) {
+	w.Header().Add("Content-Type", "application/json")
+	if req.Method == http.MethodGet {
+		mStats, err := json.Marshal(bcs.BlockchainPtr.GetPoolStats())
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		io.WriteString(w, string(mStats))
+	} else {
+		http.Error(w, "Invalid Method", http.StatusBadRequest)
+	}
+}
+
+func (bcs *BlockchainServer) GetSupply(w http.ResponseWriter, req *http.Request) {
+	w.Header().Add("Content-Type", "application/json")
+	if req.Method == http.MethodGet {
+		supply, err := bcs.BlockchainPtr.GetSupply()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		mSupply, err := json.Marshal(supply)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		io.WriteString(w, string(mSupply))
+	} else {
+		http.Error(w, "Invalid Method", http.StatusBadRequest)
+	}
+}
+
+func (bcs *BlockchainServer) GetGossipStats(w http.ResponseWriter, req *http.Request) {
+	w.Header().Add("Content-Type", "application/json")
+	if req.Method == http.MethodGet {
+		mStats, err := json.Marshal(bcs.BlockchainPtr.Gossip.Metrics())
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		io.WriteString(w, string(mStats))
+	} else {
+		http.Error(w, "Invalid Method", http.StatusBadRequest)
+	}
+}
+
+func minerErrorStatus(err error) int {
+	switch err {
+	case blockchain.ErrMinerRunning, blockchain.ErrMinerStopped:
+		return http.StatusConflict
+	default:
+		return http.StatusBadRequest
+	}
+}
+
+func (bcs *BlockchainServer) writeMinerStatus(w http.ResponseWriter) {
+	mStatus, err := json.Marshal(bcs.Miner.Status())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	io.WriteString(w, string(mStatus))
+}
+
+// StartMiner starts mining for the miners address of the node, optionally
+// with the number of workers given in the query.
+func (bcs *BlockchainServer) StartMiner(w http.ResponseWriter, req *http.Request) {
+	w.Header().Add("Content-Type", "application/json")
+	if req.Method == http.MethodPost {
+		workers, err := queryUint64(req, "workers", 0)
+		if err != nil || workers > constants.MAX_MINER_WORKERS {
+			http.Error(w, "Invalid workers", http.StatusBadRequest)
+			return
+		}
+
+		err = bcs.Miner.Start(int(workers))
+		if err != nil {
+			http.Error(w, err.Error(), minerErrorStatus(err))
+			return
+		}
+		bcs.writeMinerStatus(w)
+	} else {
+		http.Error(w, "Invalid Method", http.StatusBadRequest)
+	}
+}
+
+func (bcs *BlockchainServer) StopMiner(w http.ResponseWriter, req *http.Request) {
+	w.Header().Add("Content-Type", "application/json")
+	if req.Method == http.MethodPost {
+		err := bcs.Miner.Stop()
+		if err != nil {
+			http.Error(w, err.Error(), minerErrorStatus(err))
+			return
+		}
+		bcs.writeMinerStatus(w)
+	} else {
+		http.Error(w, "Invalid Method", http.StatusBadRequest)
+	}
+}
+
+func (bcs *BlockchainServer) GetMinerStatus(w http.ResponseWriter, req *http.Request) {
+	w.Header().Add("Content-Type", "application/json")
+	if req.Method == http.MethodGet {
+		bcs.writeMinerStatus(w)
+	} else {
+		http.Error(w, "Invalid Method", http.StatusBadRequest)
+	}
+}
+
+func (bcs *BlockchainServer) GetGenesis(w http.ResponseWriter, req *http.Request) {
+	w.Header().Add("Content-Type", "application/json")
+	if req.Method == http.MethodGet {
+		genesis, err := blockchain.GetGenesisFromDb(bcs.BlockchainPtr.Store)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+
+		mGenesis, err := json.Marshal(genesis)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		io.WriteString(w, string(mGenesis))
+	} else {
+		http.Error(w, "Invalid Method", http.StatusBadRequest)
+	}
+}
+
+func (bcs *BlockchainServer) GetAddressTransactions(w http.ResponseWriter, req *http.Request) {
+	w.Header().Add("Content-Type", "application/json")
+	if req.Method == http.MethodGet {
+		limit, err := queryUint64(req, "limit", 0)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		page, err := bcs.BlockchainPtr.GetAddressTransactions(req.URL.Query().Get("address"), req.URL.Query().Get("cursor"), int(limit))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		mPage, err := json.Marshal(page)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		io.WriteString(w, string(mPage))
+	} else {
+		http.Error(w, "Invalid Method", http.StatusBadRequest)
+	}
+}
+
+// txnErrorStatus maps the reason a transaction was refused to an HTTP status.
+func txnErrorStatus(err error) int {
+	switch err {
+	case blockchain.ErrTxnBadNonce, mempool.ErrReplacementUnderpriced, mempool.ErrNonceGap:
+		return http.StatusConflict
+	case mempool.ErrPoolFull:
+		return http.StatusServiceUnavailable
+	case blockchain.ErrTxnInsufficientFunds:
+		return http.StatusPaymentRequired
+	case blockchain.ErrTxnBadSignature, blockchain.ErrTxnWrongChain, blockchain.ErrTxnWrongSender:
+		return http.StatusUnauthorized
+	default:
+		return http.StatusUnprocessableEntity
+	}
+}
+
 func (bcs *BlockchainServer) SendTxnToTheBlockchain(w http.ResponseWriter, req *http.Request) {
 	w.Header().Add("Content-Type", "application/json")
 	if req.Method == http.MethodPost {
@@ -89,7 +541,21 @@ func (bcs *BlockchainServer) SendTxnToTheBlockchain(w http.ResponseWriter, req *
 			return
 		}
 
-		go bcs.BlockchainPtr.AddTransactionToTransactionPool(&newTxn)
+		origin, ok := bcs.peerOrigin(w, req)
+		if !ok {
+			return
+		}
+
+		err = bcs.BlockchainPtr.AddTransactionToTransactionPool(&newTxn, origin)
+		if err != nil {
+			// a node relays only transactions it admitted itself, these it
+			// could not have
+			if origin != "" && blockchain.IsMalformedTxn(err) {
+				bcs.BlockchainPtr.PeerMisbehaved(remoteHost(req), constants.INVALID_TXN_SCORE, err.Error())
+			}
+			http.Error(w, err.Error(), txnErrorStatus(err))
+			return
+		}
 
 		io.WriteString(w, newTxn.ToJson())
 	} else {
@@ -124,6 +590,10 @@ func (bcs *BlockchainServer) SendPeersList(w http.ResponseWriter, req *http.Requ
 			http.Error(w, err.Error(), http.StatusBadRequest)
 			return
 		}
+		if _, ok := bcs.peerOrigin(w, req); !ok {
+			return
+		}
+
 		go bcs.BlockchainPtr.UpdatePeers(peersList)
 		res := map[string]string{}
 		res["status"] = "success"
@@ -139,33 +609,160 @@ func (bcs *BlockchainServer) SendPeersList(w http.ResponseWriter, req *http.Requ
 	}
 }
 
+// remoteHost is the host the connection of req comes from.
+func remoteHost(req *http.Request) string {
+	host, _, err := net.SplitHostPort(req.RemoteAddr)
+	if err != nil {
+		return req.RemoteAddr
+	}
+	return host
+}
+
+// peerOrigin refuses requests from banned hosts. It returns the node a
+// request comes from, which is recorded in our address book, or an empty
+// origin for requests not sent by a node. The node address a request claims
+// is only taken when it is on the host the connection comes from.
+func (bcs *BlockchainServer) peerOrigin(w http.ResponseWriter, req *http.Request) (string, bool) {
+	host := remoteHost(req)
+	if bcs.BlockchainPtr.Peers.Banned(host) {
+		http.Error(w, "Host is banned", http.StatusForbidden)
+		return "", false
+	}
+
+	origin := req.Header.Get(constants.NODE_ADDRESS_HEADER)
+	if origin == "" || addrbook.HostOf(origin) != host {
+		return "", true
+	}
+
+	bcs.BlockchainPtr.PeerContacted(origin)
+	return origin, true
+}
+
+// GetPeers returns our address book.
+func (bcs *BlockchainServer) GetPeers(w http.ResponseWriter, req *http.Request) {
+	w.Header().Add("Content-Type", "application/json")
+	if req.Method == http.MethodGet {
+		x, err := json.Marshal(struct {
+			Peers []*addrbook.Peer `json:"peers"`
+			Hosts []*addrbook.Host `json:"hosts"`
+		}{
+			bcs.BlockchainPtr.Peers.Peers(),
+			bcs.BlockchainPtr.Peers.Hosts(),
+		})
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		io.WriteString(w, string(x))
+	} else {
+		http.Error(w, "Invalid Method", http.StatusBadRequest)
+	}
+}
+
+// AnnounceBlock takes a block announcement from the node it names and
+// processes the block in the background, so the announcing node does not
+// wait on us fetching it.
+func (bcs *BlockchainServer) AnnounceBlock(w http.ResponseWriter, req *http.Request) {
+	w.Header().Add("Content-Type", "application/json")
+	if req.Method == http.MethodPost {
+		request, err := ioutil.ReadAll(req.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		defer req.Body.Close()
+
+		var announcement blockchain.BlockAnnouncement
+		err = json.Unmarshal(request, &announcement)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if announcement.BlockHash == "" || announcement.From == "" {
+			http.Error(w, "Invalid announcement", http.StatusBadRequest)
+			return
+		}
+		origin, ok := bcs.peerOrigin(w, req)
+		if !ok {
+			return
+		}
+		// we fetch from the node named in the announcement, so it has to be
+		// the one sending it
+		if origin == "" || origin != announcement.From {
+			http.Error(w, "Announcement does not come from the node it names", http.StatusForbidden)
+			return
+		}
+
+		go bcs.BlockchainPtr.HandleBlockAnnouncement(&announcement)
+		io.WriteString(w, `{"status":"success"}`)
+	} else {
+		http.Error(w, "Invalid Method", http.StatusBadRequest)
+	}
+}
+
 func (bcs *BlockchainServer) FetchLastNBlocks(w http.ResponseWriter, req *http.Request) {
 	w.Header().Add("Content-Type", "application/json")
 	if req.Method == http.MethodGet {
-		blocks := bcs.BlockchainPtr.Blocks
-		blockchain1 := new(blockchain.BlockchainStruct)
-		if len(blocks) < constants.FETCH_LAST_N_BLOCKS {
-			blockchain1.Blocks = blocks
-		} else {
-			blockchain1.Blocks = blocks[len(blocks)-constants.FETCH_LAST_N_BLOCKS:]
+		blocks, err := bcs.BlockchainPtr.GetLastBlocks(bcs.BlockchainPtr.Config.FetchLastNBlocks)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
 		}
 
-		io.WriteString(w, blockchain1.ToJson())
+		mLast, err := json.Marshal(&blockchain.LastBlocks{Blocks: blocks})
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		io.WriteString(w, string(mLast))
 	} else {
 		http.Error(w, "Invalid Method", http.StatusBadRequest)
 	}
 }
 
 func (bcs *BlockchainServer) Start() {
-	http.HandleFunc("/", bcs.GetBlockchain)
 	http.HandleFunc("/balance", bcs.GetBalance)
-	http.HandleFunc("/get_all_non_rewarded_txns", bcs.GetAllNonRewardedTxns)
+	http.HandleFunc("/nonce", bcs.GetNonce)
+	http.HandleFunc("/merkle_proof", bcs.GetMerkleProof)
+	http.HandleFunc("/events", bcs.GetEvents)
+	http.HandleFunc("/genesis", bcs.GetGenesis)
+	http.HandleFunc("/tip", bcs.GetTip)
+	http.HandleFunc("/headers", bcs.GetHeaders)
+	http.HandleFunc("/block", bcs.GetBlock)
+	http.HandleFunc("/blocks", bcs.GetBlocks)
+	http.HandleFunc("/latest_blocks", bcs.GetLatestBlocks)
+	http.HandleFunc("/txn", bcs.GetTransaction)
+	http.HandleFunc("/receipt", bcs.GetReceipt)
+	http.HandleFunc("/address_txns", bcs.GetAddressTransactions)
+	http.HandleFunc("/txn_pool", bcs.GetPoolTransactions)
+	http.HandleFunc("/txn_pool_stats", bcs.GetPoolStats)
+	http.HandleFunc("/supply", bcs.GetSupply)
+	http.HandleFunc("/miner/status", bcs.GetMinerStatus)
 	http.HandleFunc("/send_txn", bcs.SendTxnToTheBlockchain)
 	http.HandleFunc("/send_peers_list", bcs.SendPeersList)
+	http.HandleFunc("/peers", bcs.GetPeers)
 	http.HandleFunc("/check_status", CheckStatus)
 	http.HandleFunc("/fetch_last_n_blocks", bcs.FetchLastNBlocks)
+	http.HandleFunc("/announce_block", bcs.AnnounceBlock)
+	http.HandleFunc("/gossip_stats", bcs.GetGossipStats)
 	log.Println("Launching webserver at port :", bcs.Port)
-	err := http.ListenAndServe("127.0.0.1:"+strconv.Itoa(int(bcs.Port)), nil)
+	err := http.ListenAndServe(bcs.BindAddress+":"+strconv.Itoa(int(bcs.Port)), nil)
+	if err != nil {
+		panic(err)
+	}
+}
+
+// StartAdmin serves the endpoints controlling the node on its own listener,
+// which only accepts connections from localhost.
+func (bcs *BlockchainServer) StartAdmin() {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/miner/start", bcs.StartMiner)
+	mux.HandleFunc("/miner/stop", bcs.StopMiner)
+	mux.HandleFunc("/miner/status", bcs.GetMinerStatus)
+	log.Println("Launching admin webserver at :", bcs.AdminAddress)
+	err := http.ListenAndServe(bcs.AdminAddress, mux)
 	if err != nil {
 		panic(err)
 	}
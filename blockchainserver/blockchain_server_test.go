@@ -0,0 +1,96 @@
+package blockchainserver
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/sap200/evochain/addrbook"
+	"github.com/sap200/evochain/blockchain"
+	"github.com/sap200/evochain/constants"
+)
+
+func TestPeerOriginTrustsOnlyTheConnectionHost(t *testing.T) {
+	bc := new(blockchain.BlockchainStruct)
+	// no inbound slots, so contacting nodes are not pinged back
+	bc.Peers = addrbook.New("http://127.0.0.1:5000", 8, 0)
+	bcs := NewBlockchainServer("127.0.0.1", 5000, "127.0.0.1:6000", bc, nil)
+
+	tests := []struct {
+		name       string
+		header     string
+		wantOrigin string
+	}{
+		{"no header", "", ""},
+		{"node on the connection host", "http://10.0.0.1:5000", "http://10.0.0.1:5000"},
+		{"node on another host", "http://10.0.0.2:5000", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/send_txn", nil)
+			req.RemoteAddr = "10.0.0.1:41234"
+			req.Header.Set(constants.NODE_ADDRESS_HEADER, tt.header)
+			w := httptest.NewRecorder()
+
+			origin, ok := bcs.peerOrigin(w, req)
+			if !ok || origin != tt.wantOrigin {
+				t.Fatalf("peerOrigin() = %q, %v, want %q, true", origin, ok, tt.wantOrigin)
+			}
+		})
+	}
+
+	// a banned host stays banned whatever node it claims to be
+	bc.Peers.Misbehaved("10.0.0.1", constants.BAN_SCORE)
+	for _, header := range []string{"", "http://10.0.0.1:5001", "http://10.0.0.3:5000"} {
+		req := httptest.NewRequest(http.MethodPost, "/send_txn", nil)
+		req.RemoteAddr = "10.0.0.1:41234"
+		req.Header.Set(constants.NODE_ADDRESS_HEADER, header)
+		w := httptest.NewRecorder()
+
+		_, ok := bcs.peerOrigin(w, req)
+		if ok || w.Code != http.StatusForbidden {
+			t.Fatalf("peerOrigin() with header %q = %v, status %d, want refused with 403", header, ok, w.Code)
+		}
+	}
+	if bc.Peers.Banned("10.0.0.2") {
+		t.Fatal("a host was banned for requests it did not send")
+	}
+}
+
+func TestAnnounceBlockComesFromTheNodeItNames(t *testing.T) {
+	bcs := newTestNode(t, testGenesis(nil), "")
+	bc := bcs.BlockchainPtr
+	victim := "http://10.0.0.2:5000"
+	bc.Peers.Learn([]string{victim})
+	bc.Peers.Good(victim)
+
+	tests := []struct {
+		name   string
+		header string
+		from   string
+		status int
+	}{
+		{"no node address", "", victim, http.StatusForbidden},
+		{"another host's node address", victim, victim, http.StatusForbidden},
+		{"naming another node", "http://10.0.0.1:5000", victim, http.StatusForbidden},
+		{"naming itself", "http://10.0.0.1:5000", "http://10.0.0.1:5000", http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body := `{"block_hash":"0xabc","block_number":1,"from":"` + tt.from + `"}`
+			req := httptest.NewRequest(http.MethodPost, "/announce_block", strings.NewReader(body))
+			req.RemoteAddr = "10.0.0.1:41234"
+			req.Header.Set(constants.NODE_ADDRESS_HEADER, tt.header)
+			w := httptest.NewRecorder()
+
+			bcs.AnnounceBlock(w, req)
+			if w.Code != tt.status {
+				t.Fatalf("status = %d (%s), want %d", w.Code, w.Body.String(), tt.status)
+			}
+		})
+	}
+	if bc.SeenBlocks.Has("0xabc", victim) {
+		t.Fatal("a forged announcement marked the block as seen by the peer it named")
+	}
+}
@@ -0,0 +1,79 @@
+package blockchainserver
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/sap200/evochain/blockchain"
+	"github.com/sap200/evochain/config"
+	"github.com/sap200/evochain/constants"
+	"github.com/sap200/evochain/wallet"
+)
+
+// testGenesis is an easy network for tests, every block at the proof of
+// work limit.
+func testGenesis(alloc map[string]uint64) *blockchain.Genesis {
+	g := blockchain.DefaultGenesis(config.Default())
+	g.Target = constants.POW_LIMIT
+	g.RetargetWindow = 0
+	for address, balance := range alloc {
+		g.Alloc[address] = balance
+	}
+	return g
+}
+
+// newTestNode returns the server of a node on an in-memory store initialized
+// from g, with a stopped miner paying minersAddress.
+func newTestNode(t *testing.T, g *blockchain.Genesis, minersAddress string) *BlockchainServer {
+	t.Helper()
+
+	cfg := config.Default()
+	cfg.DataDir = t.TempDir()
+	store := blockchain.NewMemoryStore()
+	err := blockchain.InitBlockchain(store, g)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	bc, err := blockchain.NewBlockchain(store, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return NewBlockchainServer("127.0.0.1", 5000, "127.0.0.1:6000", bc, blockchain.NewMiner(bc, minersAddress, 2))
+}
+
+func newTestWallet(t *testing.T) *wallet.Wallet {
+	t.Helper()
+
+	w, err := wallet.NewWallet()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return w
+}
+
+// transfer returns a transaction from w signed for the chain of bc.
+func transfer(t *testing.T, bc *blockchain.BlockchainStruct, w *wallet.Wallet, to string, value uint64, nonce uint64) *blockchain.Transaction {
+	t.Helper()
+
+	txn := blockchain.NewTransaction(w.GetAddress(), to, value, 1, nonce, []byte{})
+	txn.ChainId = bc.Params.ChainId
+	txn.TransactionHash = txn.Hash()
+	signed, err := w.GetSignedTxn(*txn, bc.Params.ChainId)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signed
+}
+
+// sendTxn posts txn to the send_txn handler and returns the response.
+func sendTxn(bcs *BlockchainServer, txn *blockchain.Transaction) *httptest.ResponseRecorder {
+	body, _ := json.Marshal(txn)
+	req := httptest.NewRequest(http.MethodPost, "/send_txn", bytes.NewReader(body))
+	w := httptest.NewRecorder()
+	bcs.SendTxnToTheBlockchain(w, req)
+	return w
+}
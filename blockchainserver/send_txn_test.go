@@ -0,0 +1,56 @@
+package blockchainserver
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/sap200/evochain/blockchain"
+)
+
+func TestSendTxnStatusCodes(t *testing.T) {
+	sender := newTestWallet(t)
+	receiver := newTestWallet(t)
+	bcs := newTestNode(t, testGenesis(map[string]uint64{sender.GetAddress(): 1000}), receiver.GetAddress())
+	bc := bcs.BlockchainPtr
+
+	tests := []struct {
+		name   string
+		txn    func() *blockchain.Transaction
+		status int
+	}{
+		{"valid", func() *blockchain.Transaction {
+			return transfer(t, bc, sender, receiver.GetAddress(), 10, 0)
+		}, http.StatusOK},
+		{"bad signature", func() *blockchain.Transaction {
+			txn := transfer(t, bc, sender, receiver.GetAddress(), 10, 1)
+			txn.Signature = transfer(t, bc, sender, receiver.GetAddress(), 11, 1).Signature
+			return txn
+		}, http.StatusUnauthorized},
+		{"insufficient funds", func() *blockchain.Transaction {
+			return transfer(t, bc, sender, receiver.GetAddress(), 1000, 1)
+		}, http.StatusPaymentRequired},
+		{"underpriced replacement", func() *blockchain.Transaction {
+			return transfer(t, bc, sender, receiver.GetAddress(), 20, 0)
+		}, http.StatusConflict},
+		{"nonce ahead", func() *blockchain.Transaction {
+			return transfer(t, bc, sender, receiver.GetAddress(), 10, 5)
+		}, http.StatusConflict},
+		{"self transfer", func() *blockchain.Transaction {
+			return transfer(t, bc, sender, sender.GetAddress(), 10, 1)
+		}, http.StatusUnprocessableEntity},
+		{"receiver not an address", func() *blockchain.Transaction {
+			return transfer(t, bc, sender, receiver.GetAddress()+":junk", 10, 1)
+		}, http.StatusUnprocessableEntity},
+		{"zero value", func() *blockchain.Transaction {
+			return transfer(t, bc, sender, receiver.GetAddress(), 0, 1)
+		}, http.StatusUnprocessableEntity},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := sendTxn(bcs, tt.txn())
+			if w.Code != tt.status {
+				t.Fatalf("status = %d (%s), want %d", w.Code, w.Body.String(), tt.status)
+			}
+		})
+	}
+}
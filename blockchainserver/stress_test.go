@@ -0,0 +1,149 @@
+package blockchainserver
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sap200/evochain/blockchain"
+	"github.com/sap200/evochain/constants"
+)
+
+// TestMiningConsensusAndApiRace mines on two nodes while the first one syncs
+// and runs consensus against the second and serves its API, all at once.
+// It is meant to be run with go test -race.
+func TestMiningConsensusAndApiRace(t *testing.T) {
+	if testing.Short() {
+		t.Skip("stress test")
+	}
+
+	sender := newTestWallet(t)
+	receiver := newTestWallet(t)
+	alloc := uint64(1000000)
+	g := testGenesis(map[string]uint64{sender.GetAddress(): alloc})
+	a := newTestNode(t, g, newTestWallet(t).GetAddress())
+	b := newTestNode(t, g, newTestWallet(t).GetAddress())
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/genesis", b.GetGenesis)
+	mux.HandleFunc("/tip", b.GetTip)
+	mux.HandleFunc("/headers", b.GetHeaders)
+	mux.HandleFunc("/block", b.GetBlock)
+	mux.HandleFunc("/check_status", CheckStatus)
+	mux.HandleFunc("/fetch_last_n_blocks", b.FetchLastNBlocks)
+	peer := httptest.NewServer(mux)
+	defer peer.Close()
+
+	a.BlockchainPtr.Peers.Learn([]string{peer.URL})
+	if !a.BlockchainPtr.Peers.Good(peer.URL) {
+		t.Fatal("the peer did not get a slot")
+	}
+
+	for _, node := range []*BlockchainServer{a, b} {
+		err := node.Miner.Start(0)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	quit := make(chan struct{})
+	var wg sync.WaitGroup
+	loop := func(f func()) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-quit:
+					return
+				default:
+					f()
+				}
+			}
+		}()
+	}
+
+	loop(func() {
+		a.BlockchainPtr.SyncFromPeers()
+	})
+	loop(func() {
+		nbc, err := blockchain.FetchLastNBlocks(peer.URL)
+		if err == nil {
+			a.BlockchainPtr.ProcessBlocks(nbc.Blocks)
+		}
+	})
+	handlers := map[string]http.HandlerFunc{
+		"/tip": a.GetTip,
+		"/balance?address=" + sender.GetAddress(): a.GetBalance,
+		"/nonce?address=" + sender.GetAddress():   a.GetNonce,
+		"/events":                                 a.GetEvents,
+		"/latest_blocks":                          a.GetLatestBlocks,
+		"/txn_pool":                               a.GetPoolTransactions,
+		"/txn_pool_stats":                         a.GetPoolStats,
+		"/supply":                                 a.GetSupply,
+		"/miner/status":                           a.GetMinerStatus,
+		"/address_txns?address=" + sender.GetAddress(): a.GetAddressTransactions,
+	}
+	for target, handler := range handlers {
+		target, handler := target, handler
+		loop(func() {
+			handler(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, target, nil))
+		})
+	}
+	loop(func() {
+		nonce := a.BlockchainPtr.GetNextNonce(sender.GetAddress())
+		sendTxn(a, transfer(t, a.BlockchainPtr, sender, receiver.GetAddress(), 1, nonce))
+		time.Sleep(10 * time.Millisecond)
+	})
+
+	// the miners start over whenever the pool changes, which makes blocks
+	// slow to come under the race detector
+	time.Sleep(2 * time.Second)
+	for deadline := time.Now().Add(30 * time.Second); time.Now().Before(deadline); time.Sleep(100 * time.Millisecond) {
+		if tip, err := a.BlockchainPtr.GetTip(); err == nil && tip.BlockNumber > 0 {
+			break
+		}
+	}
+	close(quit)
+	wg.Wait()
+	for _, node := range []*BlockchainServer{a, b} {
+		err := node.Miner.Stop()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tip, err := a.BlockchainPtr.GetTip()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tip.BlockNumber == 0 {
+		t.Fatal("no block was mined")
+	}
+	headers := []blockchain.BlockHeader{}
+	for uint64(len(headers)) <= tip.BlockNumber {
+		batch, err := a.BlockchainPtr.GetHeaders(uint64(len(headers)), constants.MAX_HEADERS_PER_REQUEST)
+		if err != nil {
+			t.Fatal(err)
+		}
+		headers = append(headers, batch...)
+	}
+	for i, h := range headers {
+		if h.BlockNumber != uint64(i) {
+			t.Fatalf("block %d is numbered %d", i, h.BlockNumber)
+		}
+		if i > 0 && h.PrevHash != headers[i-1].Hash() {
+			t.Fatalf("block %d does not link to block %d", i, i-1)
+		}
+	}
+
+	total, err := blockchain.SumBalancesFromDb(a.BlockchainPtr.Store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := alloc + a.BlockchainPtr.Params.TotalMinted(tip.BlockNumber); total != want {
+		t.Fatalf("balances add up to %d at height %d, want %d", total, tip.BlockNumber, want)
+	}
+}
@@ -0,0 +1,42 @@
+package codec
+
+import (
+	"encoding/binary"
+)
+
+// Encoder builds the canonical encoding hashes and signatures are computed
+// over. It starts with a version byte, integers are written as 8 bytes big
+// endian and byte strings are prefixed with their length as 4 bytes big
+// endian, so every value has exactly one encoding and no two sequences of
+// fields encode to the same bytes.
+type Encoder struct {
+	buf []byte
+}
+
+func NewEncoder(version byte) *Encoder {
+	e := new(Encoder)
+	e.buf = []byte{version}
+	return e
+}
+
+func (e *Encoder) PutUint64(n uint64) {
+	e.buf = binary.BigEndian.AppendUint64(e.buf, n)
+}
+
+func (e *Encoder) PutInt64(n int64) {
+	e.PutUint64(uint64(n))
+}
+
+// PutBytes writes bs with its length. Nil and empty encode the same.
+func (e *Encoder) PutBytes(bs []byte) {
+	e.buf = binary.BigEndian.AppendUint32(e.buf, uint32(len(bs)))
+	e.buf = append(e.buf, bs...)
+}
+
+func (e *Encoder) PutString(s string) {
+	e.PutBytes([]byte(s))
+}
+
+func (e *Encoder) Bytes() []byte {
+	return e.buf
+}
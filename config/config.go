@@ -0,0 +1,164 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"github.com/sap200/evochain/constants"
+	"gopkg.in/yaml.v3"
+)
+
+type Config struct {
+	DataDir            string   `yaml:"datadir"`
+	Port               uint64   `yaml:"port"`
+	BindAddress        string   `yaml:"bind_address"`
+	MinersAddress      string   `yaml:"miners_address"`
+	Mine               bool     `yaml:"mine"`          // Start mining with the node
+	MinerWorkers       int      `yaml:"miner_workers"` // Zero for one per CPU
+	AdminPort          uint64   `yaml:"admin_port"`    // Zero for port plus ADMIN_PORT_OFFSET
+	RemoteNode         string   `yaml:"remote_node"`
+	SeedPeers          []string `yaml:"seed_peers"`
+	MaxOutboundPeers   int      `yaml:"max_outbound_peers"`
+	MaxInboundPeers    int      `yaml:"max_inbound_peers"`
+	PeerPingPauseTime  int      `yaml:"peer_ping_pause_time"` // In seconds
+	ConsensusPauseTime int      `yaml:"consensus_pause_time"` // In seconds
+	FetchLastNBlocks   int      `yaml:"fetch_last_n_blocks"`
+	ChainId            uint64   `yaml:"chain_id"`
+	TargetBlockTime    uint64   `yaml:"target_block_time"` // In seconds
+	RetargetWindow     uint64   `yaml:"retarget_window"`   // In blocks
+	InitialReward      uint64   `yaml:"initial_reward"`
+	HalvingInterval    uint64   `yaml:"halving_interval"` // In blocks, zero never halves
+	TailReward         uint64   `yaml:"tail_reward"`
+	MaxSupply          uint64   `yaml:"max_supply"`     // Zero for no cap
+	MaxBlockTxns       int      `yaml:"max_block_txns"` // Transactions a mined block holds at most
+	MaxBlockSize       int      `yaml:"max_block_size"` // In bytes of transactions
+	MempoolMaxTxns     int      `yaml:"mempool_max_txns"`
+	MempoolTxnTTL      int      `yaml:"mempool_txn_ttl"` // In seconds
+}
+
+func Default() *Config {
+	cfg := new(Config)
+	cfg.DataDir = constants.DEFAULT_DATA_DIR
+	cfg.Port = constants.DEFAULT_PORT
+	cfg.BindAddress = constants.DEFAULT_BIND_ADDRESS
+	cfg.Mine = true
+	cfg.SeedPeers = []string{}
+	cfg.MaxOutboundPeers = constants.MAX_OUTBOUND_PEERS
+	cfg.MaxInboundPeers = constants.MAX_INBOUND_PEERS
+	cfg.PeerPingPauseTime = constants.PEER_PING_PAUSE_TIME
+	cfg.ConsensusPauseTime = constants.CONSENSUS_PAUSE_TIME
+	cfg.FetchLastNBlocks = constants.FETCH_LAST_N_BLOCKS
+	cfg.ChainId = constants.CHAIN_ID
+	cfg.TargetBlockTime = constants.TARGET_BLOCK_TIME
+	cfg.RetargetWindow = constants.RETARGET_WINDOW
+	cfg.InitialReward = constants.MINING_REWARD
+	cfg.HalvingInterval = constants.HALVING_INTERVAL
+	cfg.TailReward = constants.TAIL_REWARD
+	cfg.MaxSupply = constants.MAX_SUPPLY
+	cfg.MaxBlockTxns = constants.MAX_BLOCK_TXNS
+	cfg.MaxBlockSize = constants.MAX_BLOCK_SIZE
+	cfg.MempoolMaxTxns = constants.MEMPOOL_MAX_TXNS
+	cfg.MempoolTxnTTL = constants.MEMPOOL_TXN_TTL
+
+	return cfg
+}
+
+// Load reads a YAML config file on top of the defaults. Keys missing from
+// the file keep their default value.
+func Load(path string) (*Config, error) {
+	cfg := Default()
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	err = yaml.Unmarshal(data, cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	err = cfg.Validate()
+	if err != nil {
+		return nil, fmt.Errorf("invalid config %s: %s", path, err.Error())
+	}
+
+	return cfg, nil
+}
+
+// Validate refuses values the node cannot run with, naming the key at fault.
+func (cfg *Config) Validate() error {
+	if cfg.TargetBlockTime == 0 {
+		return errors.New("target_block_time must be greater than zero")
+	}
+
+	positive := []struct {
+		key   string
+		value int
+	}{
+		{"max_block_txns", cfg.MaxBlockTxns},
+		{"max_block_size", cfg.MaxBlockSize},
+		{"mempool_max_txns", cfg.MempoolMaxTxns},
+		{"mempool_txn_ttl", cfg.MempoolTxnTTL},
+		{"peer_ping_pause_time", cfg.PeerPingPauseTime},
+		{"consensus_pause_time", cfg.ConsensusPauseTime},
+	}
+	for _, p := range positive {
+		if p.value <= 0 {
+			return fmt.Errorf("%s must be greater than zero", p.key)
+		}
+	}
+
+	nonNegative := []struct {
+		key   string
+		value int
+	}{
+		{"miner_workers", cfg.MinerWorkers},
+		{"max_outbound_peers", cfg.MaxOutboundPeers},
+		{"max_inbound_peers", cfg.MaxInboundPeers},
+		{"fetch_last_n_blocks", cfg.FetchLastNBlocks},
+	}
+	for _, n := range nonNegative {
+		if n.value < 0 {
+			return fmt.Errorf("%s must not be negative", n.key)
+		}
+	}
+
+	if cfg.Port == 0 || cfg.Port > 65535 {
+		return fmt.Errorf("port %d is not between 1 and 65535", cfg.Port)
+	}
+	adminPort := cfg.AdminPort
+	if adminPort == 0 {
+		adminPort = cfg.Port + constants.ADMIN_PORT_OFFSET
+	}
+	if adminPort > 65535 {
+		return fmt.Errorf("admin_port %d is not between 1 and 65535", adminPort)
+	}
+
+	return nil
+}
+
+func (cfg *Config) DbPath() string {
+	return filepath.Join(cfg.DataDir, constants.DB_DIR_NAME)
+}
+
+// NodeAddress is the URL other nodes use to reach this node.
+func (cfg *Config) NodeAddress() string {
+	return "http://" + cfg.ListenAddress()
+}
+
+func (cfg *Config) ListenAddress() string {
+	return cfg.BindAddress + ":" + strconv.Itoa(int(cfg.Port))
+}
+
+// AdminListenAddress is where the admin API listens, on localhost only.
+func (cfg *Config) AdminListenAddress() string {
+	port := cfg.AdminPort
+	if port == 0 {
+		port = cfg.Port + constants.ADMIN_PORT_OFFSET
+	}
+	return constants.ADMIN_BIND_ADDRESS + ":" + strconv.Itoa(int(port))
+}
@@ -0,0 +1,45 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLoadValidates(t *testing.T) {
+	tests := []struct {
+		yaml    string
+		wantErr string // empty when the config is valid
+	}{
+		{"", ""},
+		{"mempool_txn_ttl: 60\nmax_block_txns: 10", ""},
+		{"mempool_txn_ttl: 0", "mempool_txn_ttl"},
+		{"mempool_txn_ttl: -5", "mempool_txn_ttl"},
+		{"target_block_time: 0", "target_block_time"},
+		{"mempool_max_txns: 0", "mempool_max_txns"},
+		{"max_block_txns: -1", "max_block_txns"},
+		{"max_block_size: 0", "max_block_size"},
+		{"consensus_pause_time: 0", "consensus_pause_time"},
+		{"miner_workers: -2", "miner_workers"},
+		{"port: 70000", "port"},
+		{"port: 65000", "admin_port"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.yaml, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "node.yaml")
+			err := os.WriteFile(path, []byte(tt.yaml), 0644)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			_, err = Load(path)
+			if tt.wantErr == "" && err != nil {
+				t.Fatalf("Load() error = %v", err)
+			}
+			if tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+				t.Fatalf("Load() error = %v, want one naming %s", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -1,25 +1,104 @@
 package constants
 
 const (
-	BLOCKCHAIN_NAME           = "Evochain"
-	HEX_PREFIX                = "0x"
-	SUCCESS                   = "success"
-	FAILED                    = "failed"
-	PENDING                   = "pending"
-	MINING_DIFFICULTY         = 5
-	MINING_REWARD             = 1200 * DECIMAL
-	CURRENCY_NAME             = "evo"
-	DECIMAL                   = 100
-	BLOCKCHAIN_ADDRESS        = "Evochain_Faucet"
-	BLOCKCHAIN_DB_PATH        = "5000/evodb"
-	BLOCKCHAIN_KEY            = "blockchain_key"
-	ADDRESS_PREFIX            = "evochain"
-	TXN_VERIFICATION_SUCCESS  = "verification_success"
-	TXN_VERIFICATION_FAILURE  = "verification_failure"
-	BLOCKCHAIN_STATUS         = "RUNNING"
-	PEER_BROADCAST_PAUSE_TIME = 1  // In seconds
-	PEER_PING_PAUSE_TIME      = 60 // In seconds
-	TXN_BROADCAST_PAUSE_TIME  = 1  // In seconds
-	FETCH_LAST_N_BLOCKS       = 50
-	CONSENSUS_PAUSE_TIME      = 10 // In seconds
+	BLOCKCHAIN_NAME          = "Evochain"
+	HEX_PREFIX               = "0x"
+	SUCCESS                  = "success"
+	FAILED                   = "failed"
+	PENDING                  = "pending"
+	CHAIN_ID                 = 1
+	BLOCK_VERSION            = 1 // canonical header encoding
+	TXN_ENCODING_VERSION     = 1
+	TXN_SIGNING_DOMAIN       = "Evochain signed transaction:" // prefixed to what a sender signs
+	GENESIS_TIMESTAMP        = 1700000000000000000            // In nanoseconds
+	INITIAL_TARGET           = "0x00000fffffffffffffffffffffffffffffffffffffffffffffffffffffffffff"
+	POW_LIMIT                = "0x0000ffffffffffffffffffffffffffffffffffffffffffffffffffffffffffff"
+	TARGET_BLOCK_TIME        = 10 // In seconds
+	RETARGET_WINDOW          = 10 // In blocks
+	MAX_RETARGET_FACTOR      = 4
+	MAX_BLOCK_TXNS           = 1000
+	MAX_BLOCK_SIZE           = 1000000 // In bytes
+	MEMPOOL_MAX_TXNS         = 5000
+	MEMPOOL_TXN_TTL          = 3 * 60 * 60 // In seconds
+	MEMPOOL_MIN_FEE_BUMP     = 10          // In percent of the fee a replacement outbids
+	EVENT_NEW_TIP            = "new_tip"
+	EVENT_REORG              = "reorg"
+	EVENT_SUBSCRIBER_BUFFER  = 16
+	RECENT_EVENTS            = 100
+	MINING_REWARD            = 1200 * DECIMAL // Initial reward of the emission schedule
+	HALVING_INTERVAL         = 100000         // In blocks
+	TAIL_REWARD              = 0
+	MAX_SUPPLY               = 0 // Zero for no cap
+	CURRENCY_NAME            = "evo"
+	DECIMAL                  = 100
+	BLOCKCHAIN_ADDRESS       = "Evochain_Faucet"
+	DEFAULT_DATA_DIR         = "evodata"
+	DEFAULT_PORT             = 5000
+	DEFAULT_BIND_ADDRESS     = "127.0.0.1"
+	ADMIN_BIND_ADDRESS       = "127.0.0.1" // the admin API is never reachable from other hosts
+	ADMIN_PORT_OFFSET        = 1000
+	DB_DIR_NAME              = "evodb"
+	BLOCKCHAIN_KEY           = "blockchain_key" // legacy single blob layout
+	LEGACY_BLOCK_KEY_PREFIX  = "l:b:"           // blocks of a migrated single blob database, by number
+	BLOCK_HASH_KEY_PREFIX    = "b:h:"           // block by hash
+	BLOCK_NUMBER_KEY_PREFIX  = "b:n:"           // canonical block hash by number
+	TXN_KEY_PREFIX           = "t:"             // transaction location by hash
+	ADDRESS_TXN_KEY_PREFIX   = "x:"             // transactions of an address, newest first
+	ACCOUNT_KEY_PREFIX       = "a:"             // account state by address
+	UNDO_KEY_PREFIX          = "u:"             // accounts touched by a block, before it
+	TOTAL_WORK_KEY_PREFIX    = "w:"             // cumulative proof of work up to a block
+	STATE_TIP_KEY            = "m:state_tip"    // block the account state is at
+	INDEX_VERSION_KEY        = "m:index_version"
+	INDEX_VERSION            = 1 // bump to reindex databases written by older nodes
+	TIP_KEY                  = "m:tip"
+	HEIGHT_KEY               = "m:height"
+	TXN_POOL_KEY             = "m:txn_pool"
+	ADDRESS_BOOK_KEY         = "m:address_book"
+	PEER_HOSTS_KEY           = "m:peer_hosts" // misbehavior scores and bans by host
+	GENESIS_KEY              = "m:genesis"    // genesis the chain was initialized from
+	SYNC_HEADER_KEY_PREFIX   = "s:h:"         // validated headers whose blocks are not downloaded yet, by number
+	SYNC_PEER_KEY            = "m:sync_peer"  // peer whose chain the sync headers are of
+	ADDRESS_PREFIX           = "evochain"
+	TXN_VERIFICATION_SUCCESS = "verification_success"
+	RECEIPT_PENDING          = "pending"
+	RECEIPT_REJECTED         = "rejected"
+	RECEIPT_INCLUDED         = "included"
+	MAX_REJECTED_TXNS        = 1000
+	BLOCKCHAIN_STATUS        = "RUNNING"
+	MAX_OUTBOUND_PEERS       = 8
+	MAX_INBOUND_PEERS        = 32
+	MAX_KNOWN_PEERS          = 1000
+	MAX_SCORED_HOSTS         = 1000
+	MAX_SHARED_PEERS         = 100         // addresses sent or taken in one peers list
+	MAX_PEER_FAILURES        = 10          // failed pings in a row before a peer is forgotten
+	PEER_RETRY_INTERVAL      = 60          // In seconds, times the failures of the peer
+	PEER_SHARE_WINDOW        = 3 * 60 * 60 // In seconds
+	BAN_SCORE                = 100
+	BAN_DURATION             = 24 * 60 * 60 // In seconds
+	INVALID_BLOCK_SCORE      = 100
+	INVALID_TXN_SCORE        = 20
+	PEER_PING_PAUSE_TIME     = 60 // In seconds
+	FETCH_LAST_N_BLOCKS      = 50
+	CONSENSUS_PAUSE_TIME     = 10  // In seconds
+	MAX_FUTURE_BLOCK_TIME    = 120 // In seconds
+	MAX_HEADERS_PER_REQUEST  = 500
+	SYNC_WINDOW              = 64 // In blocks
+	SYNC_WORKERS             = 4
+	SYNC_REQUEST_TIMEOUT     = 30   // In seconds
+	MINER_HASH_BATCH         = 4096 // hashes a miner worker tries between checks for a new template
+	ANNOUNCE_REQUEST_TIMEOUT = 10   // In seconds
+	MAX_ANNOUNCED_ANCESTORS  = 16   // missing parents fetched for an announced block before syncing instead
+	SEEN_BLOCKS              = 1000
+	SEEN_TXNS                = 10000
+	GOSSIP_QUEUE_SIZE        = 256 // messages waiting for a peer before new ones are dropped
+	GOSSIP_WORKERS           = 16  // gossip requests in flight at once
+	GOSSIP_REQUEST_TIMEOUT   = 5   // In seconds
+	GOSSIP_MAX_RETRIES       = 3
+	GOSSIP_RETRY_BACKOFF     = 500               // In milliseconds, doubled on every retry
+	GOSSIP_IDLE_TIMEOUT      = 60                // In seconds
+	NODE_ADDRESS_HEADER      = "X-Evochain-Node" // address of the node sending a gossip request
+	MAX_MINER_WORKERS        = 1024
+	MINER_POLL_INTERVAL      = 250 // In milliseconds
+	DEFAULT_PAGE_SIZE        = 20
+	MAX_PAGE_SIZE            = 100
 )
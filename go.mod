@@ -3,6 +3,8 @@ module github.com/sap200/evochain
 go 1.21
 
 require (
-	github.com/golang/snappy v0.0.0-20180518054509-2e65f85255db // indirect
-	github.com/syndtr/goleveldb v1.0.0 // indirect
+	github.com/syndtr/goleveldb v1.0.0
+	gopkg.in/yaml.v3 v3.0.1
 )
+
+require github.com/golang/snappy v0.0.0-20180518054509-2e65f85255db // indirect
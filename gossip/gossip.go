@@ -0,0 +1,162 @@
+package gossip
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/sap200/evochain/constants"
+)
+
+type Metrics struct {
+	Queued     int            `json:"queued"`      // Messages waiting in every queue
+	QueueDepth map[string]int `json:"queue_depth"` // Messages waiting by peer
+	InFlight   int            `json:"in_flight"`   // Requests being sent
+	Sent       uint64         `json:"sent"`
+	Retries    uint64         `json:"retries"`
+	Failed     uint64         `json:"failed"`  // Given up on after the last retry
+	Dropped    uint64         `json:"dropped"` // Refused by a full queue
+}
+
+type message struct {
+	path string
+	body []byte
+}
+
+// Gossip posts messages to peers in the background. Every peer has a bounded
+// queue drained in order by a goroutine of its own, so a slow or dead peer
+// only holds up the messages for it, and a full queue drops new messages.
+// Requests in flight are bounded across all peers, and failed ones are
+// retried with exponential backoff.
+type Gossip struct {
+	self    string
+	client  *http.Client
+	slots   chan struct{}
+	mu      sync.Mutex
+	queues  map[string]chan *message
+	sent    atomic.Uint64
+	retries atomic.Uint64
+	failed  atomic.Uint64
+	dropped atomic.Uint64
+}
+
+// New returns the gossip of the node at address self, which it tells every
+// peer it posts to.
+func New(self string) *Gossip {
+	g := new(Gossip)
+	g.self = self
+	g.client = &http.Client{Timeout: constants.GOSSIP_REQUEST_TIMEOUT * time.Second}
+	g.slots = make(chan struct{}, constants.GOSSIP_WORKERS)
+	g.queues = map[string]chan *message{}
+	return g
+}
+
+// Send queues body to be posted to path on peer and reports whether the
+// queue had room for it.
+func (g *Gossip) Send(peer string, path string, body []byte) bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	queue, ok := g.queues[peer]
+	if !ok {
+		queue = make(chan *message, constants.GOSSIP_QUEUE_SIZE)
+		g.queues[peer] = queue
+		go g.drain(peer, queue)
+	}
+
+	select {
+	case queue <- &message{path: path, body: body}:
+		return true
+	default:
+		g.dropped.Add(1)
+		return false
+	}
+}
+
+// drain delivers the messages queued for peer, and retires the queue once it
+// has been empty for a while.
+func (g *Gossip) drain(peer string, queue chan *message) {
+	for {
+		select {
+		case msg := <-queue:
+			g.deliver(peer, msg)
+		case <-time.After(constants.GOSSIP_IDLE_TIMEOUT * time.Second):
+			g.mu.Lock()
+			if len(queue) == 0 {
+				delete(g.queues, peer)
+				g.mu.Unlock()
+				return
+			}
+			g.mu.Unlock()
+		}
+	}
+}
+
+func (g *Gossip) deliver(peer string, msg *message) {
+	backoff := constants.GOSSIP_RETRY_BACKOFF * time.Millisecond
+	for attempt := 0; ; attempt++ {
+		err := g.post(peer, msg)
+		if err == nil {
+			g.sent.Add(1)
+			return
+		}
+
+		if attempt == constants.GOSSIP_MAX_RETRIES {
+			g.failed.Add(1)
+			log.Println("Giving up on gossip to the peer:", peer, "Path:", msg.path, "Error:", err.Error())
+			return
+		}
+		g.retries.Add(1)
+		time.Sleep(backoff)
+		backoff *= 2
+	}
+}
+
+// post sends msg once. Only failures to reach the peer and server errors are
+// worth a retry, a peer refusing the message would refuse it again.
+func (g *Gossip) post(peer string, msg *message) error {
+	g.slots <- struct{}{}
+	defer func() { <-g.slots }()
+
+	req, err := http.NewRequest(http.MethodPost, peer+msg.path, bytes.NewReader(msg.body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(constants.NODE_ADDRESS_HEADER, g.self)
+
+	resp, err := g.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode >= http.StatusInternalServerError {
+		return fmt.Errorf("%s returned %s", peer+msg.path, resp.Status)
+	}
+	return nil
+}
+
+func (g *Gossip) Metrics() *Metrics {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	m := new(Metrics)
+	m.QueueDepth = map[string]int{}
+	for peer, queue := range g.queues {
+		m.QueueDepth[peer] = len(queue)
+		m.Queued += len(queue)
+	}
+	m.InFlight = len(g.slots)
+	m.Sent = g.sent.Load()
+	m.Retries = g.retries.Load()
+	m.Failed = g.failed.Load()
+	m.Dropped = g.dropped.Load()
+	return m
+}
@@ -0,0 +1,151 @@
+package gossip
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sap200/evochain/constants"
+)
+
+// waitFor polls the metrics of g until done accepts them.
+func waitFor(t *testing.T, g *Gossip, timeout time.Duration, done func(m *Metrics) bool) *Metrics {
+	t.Helper()
+
+	deadline := time.Now().Add(timeout)
+	for {
+		m := g.Metrics()
+		if done(m) {
+			return m
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("gossip metrics stuck at %+v", m)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestSendDropsMessagesOfAFullQueue(t *testing.T) {
+	received := make(chan struct{}, constants.GOSSIP_QUEUE_SIZE+1)
+	release := make(chan struct{})
+	peer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		received <- struct{}{}
+		<-release
+	}))
+	defer peer.Close()
+
+	g := New("http://127.0.0.1:5000")
+	if !g.Send(peer.URL, "/send_txn", []byte("{}")) {
+		t.Fatal("Send() refused the first message")
+	}
+	// the first message is in flight, held by the peer
+	<-received
+
+	for i := 0; i < constants.GOSSIP_QUEUE_SIZE; i++ {
+		if !g.Send(peer.URL, "/send_txn", []byte("{}")) {
+			t.Fatalf("Send() refused message %d of a queue with room", i)
+		}
+	}
+	if g.Send(peer.URL, "/send_txn", []byte("{}")) {
+		t.Fatal("Send() took a message into a full queue")
+	}
+
+	m := g.Metrics()
+	if m.Dropped != 1 || m.Queued != constants.GOSSIP_QUEUE_SIZE || m.QueueDepth[peer.URL] != constants.GOSSIP_QUEUE_SIZE {
+		t.Fatalf("metrics = %+v, want one dropped and a full queue", m)
+	}
+
+	close(release)
+	waitFor(t, g, 10*time.Second, func(m *Metrics) bool {
+		return m.Sent == constants.GOSSIP_QUEUE_SIZE+1
+	})
+}
+
+func TestDeliverRetriesWithBackoff(t *testing.T) {
+	var mu sync.Mutex
+	attempts := map[string][]time.Time{}
+	handler := http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.Header.Get(constants.NODE_ADDRESS_HEADER) != "http://127.0.0.1:5000" {
+			t.Errorf("gossip to %s does not name the sending node", req.URL.Path)
+		}
+
+		mu.Lock()
+		attempts[req.URL.Path] = append(attempts[req.URL.Path], time.Now())
+		tries := len(attempts[req.URL.Path])
+		mu.Unlock()
+
+		switch {
+		case req.URL.Path == "/flaky" && tries > 2:
+		case req.URL.Path == "/refused":
+			w.WriteHeader(http.StatusBadRequest)
+		default:
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	})
+
+	// a peer for every path, so their queues are delivered side by side
+	g := New("http://127.0.0.1:5000")
+	for _, path := range []string{"/flaky", "/refused", "/down"} {
+		peer := httptest.NewServer(handler)
+		defer peer.Close()
+		g.Send(peer.URL, path, []byte("{}"))
+	}
+
+	m := waitFor(t, g, 10*time.Second, func(m *Metrics) bool {
+		return m.Sent+m.Failed == 3
+	})
+	if m.Sent != 2 || m.Failed != 1 || m.Retries != 2+constants.GOSSIP_MAX_RETRIES {
+		t.Fatalf("metrics = %+v, want 2 sent, 1 failed and %d retries", m, 2+constants.GOSSIP_MAX_RETRIES)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(attempts["/refused"]) != 1 {
+		t.Fatalf("a refused message was posted %d times, want once", len(attempts["/refused"]))
+	}
+	if len(attempts["/flaky"]) != 3 {
+		t.Fatalf("a flaky peer was posted to %d times, want 3", len(attempts["/flaky"]))
+	}
+	down := attempts["/down"]
+	if len(down) != constants.GOSSIP_MAX_RETRIES+1 {
+		t.Fatalf("a down peer was posted to %d times, want %d", len(down), constants.GOSSIP_MAX_RETRIES+1)
+	}
+	backoff := constants.GOSSIP_RETRY_BACKOFF * time.Millisecond
+	for i := 1; i < len(down); i++ {
+		if gap := down[i].Sub(down[i-1]); gap < backoff {
+			t.Fatalf("retry %d came after %v, want at least %v", i, gap, backoff)
+		}
+		backoff *= 2
+	}
+}
+
+func TestSeenCache(t *testing.T) {
+	c := NewSeenCache(2)
+
+	if !c.Add("a", "peer1") {
+		t.Fatal("Add() of a new hash reported it seen")
+	}
+	if c.Add("a", "peer2") {
+		t.Fatal("Add() of a seen hash reported it new")
+	}
+	if !c.Has("a", "peer1") || !c.Has("a", "peer2") || c.Has("a", "peer3") {
+		t.Fatal("Has() does not match the peers the hash was added from")
+	}
+
+	c.Add("b", "")
+	if c.Has("b", "") {
+		t.Fatal("Add() with no peer recorded an empty peer")
+	}
+	c.Add("c", "peer1")
+	if c.Has("a", "peer1") || !c.Add("a", "") {
+		t.Fatal("the oldest hash was kept past the size of the cache")
+	}
+
+	c.Remove("c")
+	if c.Has("c", "peer1") || !c.Add("c", "") {
+		t.Fatal("Remove() kept the hash")
+	}
+	c.Remove("unknown")
+}
@@ -0,0 +1,71 @@
+package gossip
+
+import (
+	"sync"
+)
+
+// SeenCache remembers, for the most recent hashes, the peers known to have
+// them: the ones they came from and the ones they were sent to. Oldest hashes
+// are forgotten first.
+type SeenCache struct {
+	mu    sync.Mutex
+	size  int
+	peers map[string]map[string]bool
+	order []string
+}
+
+func NewSeenCache(size int) *SeenCache {
+	c := new(SeenCache)
+	c.size = size
+	c.peers = map[string]map[string]bool{}
+	c.order = []string{}
+	return c
+}
+
+// Add records that peer has hash and reports whether hash is new to us. An
+// empty peer records the hash alone.
+func (c *SeenCache) Add(hash string, peer string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	peers, ok := c.peers[hash]
+	if !ok {
+		peers = map[string]bool{}
+		c.peers[hash] = peers
+		c.order = append(c.order, hash)
+
+		if len(c.order) > c.size {
+			delete(c.peers, c.order[0])
+			c.order = c.order[1:]
+		}
+	}
+	if peer != "" {
+		peers[peer] = true
+	}
+	return !ok
+}
+
+// Has reports whether peer is known to have hash.
+func (c *SeenCache) Has(hash string, peer string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.peers[hash][peer]
+}
+
+// Remove forgets hash, so it is taken again from the next peer offering it.
+func (c *SeenCache) Remove(hash string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if _, ok := c.peers[hash]; !ok {
+		return
+	}
+	delete(c.peers, hash)
+	for i, h := range c.order {
+		if h == hash {
+			c.order = append(c.order[:i], c.order[i+1:]...)
+			break
+		}
+	}
+}
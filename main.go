@@ -5,11 +5,15 @@ import (
 	"fmt"
 	"log"
 	"os"
-	"strconv"
+	"os/signal"
+	"strings"
 	"sync"
+	"syscall"
 
+	"github.com/sap200/evochain/address"
 	"github.com/sap200/evochain/blockchain"
 	"github.com/sap200/evochain/blockchainserver"
+	"github.com/sap200/evochain/config"
 	"github.com/sap200/evochain/constants"
 	"github.com/sap200/evochain/walletserver"
 )
@@ -18,17 +22,220 @@ func init() {
 	log.SetPrefix(constants.BLOCKCHAIN_NAME + ":")
 }
 
+func closeStoreOnInterrupt(store blockchain.Store) {
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-sigs
+		log.Println("Closing the database")
+		store.Close()
+		os.Exit(0)
+	}()
+}
+
+// loadConfig reads the config file if one was given and applies the flags
+// that were explicitly set on the command line on top of it.
+func loadConfig(path string, cmdSet *flag.FlagSet) *config.Config {
+	cfg := config.Default()
+	if path != "" {
+		var err error
+		cfg, err = config.Load(path)
+		if err != nil {
+			fmt.Println(err.Error())
+			os.Exit(1)
+		}
+	}
+
+	// -seed is an alias of -bootnodes, the addresses of both are kept
+	var bootnodes []string
+	cmdSet.Visit(func(f *flag.Flag) {
+		getter := f.Value.(flag.Getter)
+		switch f.Name {
+		case "datadir":
+			cfg.DataDir = getter.Get().(string)
+		case "port":
+			cfg.Port = getter.Get().(uint64)
+		case "bind":
+			cfg.BindAddress = getter.Get().(string)
+		case "miners_address":
+			cfg.MinersAddress = getter.Get().(string)
+		case "mine":
+			cfg.Mine = getter.Get().(bool)
+		case "miner_workers":
+			cfg.MinerWorkers = getter.Get().(int)
+		case "admin_port":
+			cfg.AdminPort = getter.Get().(uint64)
+		case "remote_node":
+			cfg.RemoteNode = getter.Get().(string)
+		case "bootnodes", "seed":
+			bootnodes = append(bootnodes, splitAddresses(getter.Get().(string))...)
+			cfg.SeedPeers = bootnodes
+		}
+	})
+
+	err := cfg.Validate()
+	if err != nil {
+		fmt.Println(err.Error())
+		os.Exit(1)
+	}
+
+	return cfg
+}
+
+// splitAddresses splits a comma separated list of node addresses.
+func splitAddresses(list string) []string {
+	addresses := []string{}
+	for _, address := range strings.Split(list, ",") {
+		address = strings.TrimSpace(address)
+		if address != "" {
+			addresses = append(addresses, address)
+		}
+	}
+
+	return addresses
+}
+
+func openStore(cfg *config.Config) blockchain.Store {
+	err := os.MkdirAll(cfg.DataDir, 0755)
+	if err != nil {
+		fmt.Println(err.Error())
+		os.Exit(1)
+	}
+
+	store, err := blockchain.OpenLevelDbStore(cfg.DbPath())
+	if err != nil {
+		fmt.Println(err.Error())
+		os.Exit(1)
+	}
+
+	return store
+}
+
+// runChainInit writes the genesis block described by the genesis file, or
+// by the config when none is given. Nodes initialized from the same genesis
+// get the same genesis block.
+func runChainInit(cfg *config.Config, genesisPath string) {
+	genesis := blockchain.DefaultGenesis(cfg)
+	if genesisPath != "" {
+		var err error
+		genesis, err = blockchain.LoadGenesis(genesisPath, cfg)
+		if err != nil {
+			fmt.Println(err.Error())
+			os.Exit(1)
+		}
+	}
+
+	store := openStore(cfg)
+	defer store.Close()
+
+	err := blockchain.InitBlockchain(store, genesis)
+	if err != nil {
+		fmt.Println(err.Error())
+		os.Exit(1)
+	}
+
+	log.Println("Initialized", cfg.DataDir, "with genesis block", genesis.Block().Hash())
+}
+
+func runChainReindex(cfg *config.Config) {
+	store := openStore(cfg)
+	defer store.Close()
+
+	err := blockchain.Reindex(store)
+	if err != nil {
+		fmt.Println(err.Error())
+		os.Exit(1)
+	}
+}
+
+func runChain(cfg *config.Config) {
+	var wg sync.WaitGroup
+
+	store := openStore(cfg)
+	closeStoreOnInterrupt(store)
+
+	if cfg.RemoteNode != "" {
+		exists, err := blockchain.HasBlockchain(store)
+		if err != nil {
+			fmt.Println(err.Error())
+			os.Exit(1)
+		}
+
+		// a fresh node takes its genesis from the remote node
+		if !exists {
+			genesis, err := blockchain.FetchGenesis(cfg.RemoteNode)
+			if err != nil {
+				fmt.Println(err.Error())
+				os.Exit(1)
+			}
+
+			err = blockchain.InitBlockchain(store, genesis)
+			if err != nil {
+				fmt.Println(err.Error())
+				os.Exit(1)
+			}
+		}
+	}
+
+	blockchain1, err := blockchain.NewBlockchain(store, cfg)
+	if err != nil {
+		fmt.Println(err.Error())
+		os.Exit(1)
+	}
+
+	err = blockchain1.SyncFromPeers()
+	if err != nil {
+		log.Println("Error while syncing from peers:", err.Error())
+	}
+
+	miner := blockchain.NewMiner(blockchain1, cfg.MinersAddress, cfg.MinerWorkers)
+	if cfg.Mine {
+		err = miner.Start(0)
+		if err != nil {
+			fmt.Println(err.Error())
+			os.Exit(1)
+		}
+	}
+
+	bcs := blockchainserver.NewBlockchainServer(cfg.BindAddress, cfg.Port, cfg.AdminListenAddress(), blockchain1, miner)
+	wg.Add(5)
+	go bcs.Start()
+	go bcs.StartAdmin()
+	go bcs.BlockchainPtr.AnnounceBlocks()
+	go bcs.BlockchainPtr.DialAndUpdatePeers()
+	go bcs.BlockchainPtr.RunConsensus()
+	wg.Wait()
+}
+
 func main() {
 
 	chainCmdSet := flag.NewFlagSet("chain", flag.ExitOnError)
+	chainInitCmdSet := flag.NewFlagSet("chain init", flag.ExitOnError)
+	chainReindexCmdSet := flag.NewFlagSet("chain reindex", flag.ExitOnError)
 	walletCmdSet := flag.NewFlagSet("wallet", flag.ExitOnError)
 
-	chainPort := chainCmdSet.Uint64("port", 5000, "HTTP port to launch our blockchain server")
-	chainMiner := chainCmdSet.String("miners_address", "", "Miners address to credit mining reward")
-	remoteNode := chainCmdSet.String("remote_node", "", "Remote Node from where the blockchain will be synced")
+	chainConfig := chainCmdSet.String("config", "", "Path to a YAML config file for the node")
+	chainCmdSet.String("datadir", constants.DEFAULT_DATA_DIR, "Directory holding the node's database")
+	chainCmdSet.Uint64("port", constants.DEFAULT_PORT, "HTTP port to launch our blockchain server")
+	chainCmdSet.String("bind", constants.DEFAULT_BIND_ADDRESS, "Address to bind our blockchain server to")
+	chainCmdSet.String("miners_address", "", "Miners address to credit mining reward")
+	chainCmdSet.Bool("mine", true, "Start mining with the node, it can be started later with /miner/start")
+	chainCmdSet.Int("miner_workers", 0, "Goroutines mining in parallel, one per CPU when zero")
+	chainCmdSet.Uint64("admin_port", 0, "Port of the admin API on localhost, port plus 1000 when zero")
+	chainCmdSet.String("remote_node", "", "Remote Node to take the genesis block from and sync the blockchain with")
+	chainCmdSet.String("bootnodes", "", "Comma separated node addresses to find peers from, replaces seed_peers")
+	chainCmdSet.String("seed", "", "Same as bootnodes")
+
+	chainInitConfig := chainInitCmdSet.String("config", "", "Path to a YAML config file for the node")
+	chainInitCmdSet.String("datadir", constants.DEFAULT_DATA_DIR, "Directory to create the node's database in")
+	chainInitGenesis := chainInitCmdSet.String("genesis", "", "Path to a genesis JSON file, the config is used when none is given")
+
+	chainReindexConfig := chainReindexCmdSet.String("config", "", "Path to a YAML config file for the node")
+	chainReindexCmdSet.String("datadir", constants.DEFAULT_DATA_DIR, "Directory holding the node's database")
 
 	walletPort := walletCmdSet.Uint64("port", 8080, "HTTP port to launch our wallet server")
 	blockchainNodeAddress := walletCmdSet.String("node_address", "http://127.0.0.1:5000", "Blockchain node address for the wallet gateway")
+	walletChainId := walletCmdSet.Uint64("chain_id", 0, "Chain ID to sign transactions for, the node's when zero")
 
 	if len(os.Args) < 2 {
 		fmt.Println("Error:Expected chain or wallet subcommand")
@@ -37,46 +244,32 @@ func main() {
 
 	switch os.Args[1] {
 	case "chain":
-		var wg sync.WaitGroup
+		if len(os.Args) > 2 && os.Args[2] == "init" {
+			chainInitCmdSet.Parse(os.Args[3:])
+			runChainInit(loadConfig(*chainInitConfig, chainInitCmdSet), *chainInitGenesis)
+			return
+		}
+
+		if len(os.Args) > 2 && os.Args[2] == "reindex" {
+			chainReindexCmdSet.Parse(os.Args[3:])
+			runChainReindex(loadConfig(*chainReindexConfig, chainReindexCmdSet))
+			return
+		}
 
 		chainCmdSet.Parse(os.Args[2:])
 		if chainCmdSet.Parsed() {
-			if *chainMiner == "" || chainCmdSet.NFlag() == 0 {
+			cfg := loadConfig(*chainConfig, chainCmdSet)
+			if cfg.Mine && !address.IsValid(cfg.MinersAddress) {
 				fmt.Println("Usage of chain subcommand: ")
 				chainCmdSet.PrintDefaults()
+				fmt.Println("Usage of chain init subcommand: ")
+				chainInitCmdSet.PrintDefaults()
+				fmt.Println("Usage of chain reindex subcommand: ")
+				chainReindexCmdSet.PrintDefaults()
 				os.Exit(1)
 			}
 
-			if *remoteNode == "" {
-
-				genesisBlock := blockchain.NewBlock("0x0", 0, 0)
-				blockchain1 := blockchain.NewBlockchain(*genesisBlock, "http://127.0.0.1:"+strconv.Itoa(int(*chainPort)))
-				blockchain1.Peers[blockchain1.Address] = true
-				bcs := blockchainserver.NewBlockchainServer(*chainPort, blockchain1)
-				wg.Add(4)
-				go bcs.Start()
-				go bcs.BlockchainPtr.ProofOfWorkMining(*chainMiner)
-				go bcs.BlockchainPtr.DialAndUpdatePeers()
-				go bcs.BlockchainPtr.RunConsensus()
-				wg.Wait()
-			} else {
-				blockchain1, err := blockchain.SyncBlockchain(*remoteNode)
-				if err != nil {
-					fmt.Println(err.Error())
-					os.Exit(1)
-				}
-
-				blockchain2 := blockchain.NewBlockchainFromSync(blockchain1, "http://127.0.0.1:"+strconv.Itoa(int(*chainPort)))
-				blockchain2.Peers[blockchain2.Address] = true
-				bcs := blockchainserver.NewBlockchainServer(*chainPort, blockchain2)
-				wg.Add(4)
-				go bcs.Start()
-				go bcs.BlockchainPtr.ProofOfWorkMining(*chainMiner)
-				go bcs.BlockchainPtr.DialAndUpdatePeers()
-				go bcs.BlockchainPtr.RunConsensus()
-				wg.Wait()
-			}
-
+			runChain(cfg)
 		}
 	case "wallet":
 		walletCmdSet.Parse(os.Args[2:])
@@ -87,7 +280,7 @@ func main() {
 				os.Exit(1)
 			}
 
-			ws := walletserver.NewWalletServer(*walletPort, *blockchainNodeAddress)
+			ws := walletserver.NewWalletServer(*walletPort, *blockchainNodeAddress, *walletChainId)
 			ws.Start()
 		}
 	default:
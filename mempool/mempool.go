@@ -0,0 +1,550 @@
+package mempool
+
+import (
+	"container/heap"
+	"errors"
+	"fmt"
+	"math/big"
+	"math/bits"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/sap200/evochain/constants"
+)
+
+var (
+	ErrKnown                  = errors.New("transaction is already in the pool")
+	ErrReplacementUnderpriced = fmt.Errorf("replacement transaction must pay a fee at least %d%% higher", constants.MEMPOOL_MIN_FEE_BUMP)
+	ErrPoolFull               = errors.New("transaction pool is full and the fee rate is too low to evict any transaction")
+	ErrNonceGap               = errors.New("transaction nonce does not follow the sender's transactions in the pool")
+)
+
+// Txn is what the pool needs to know about a transaction.
+type Txn interface {
+	GetHash() string
+	GetSender() string
+	GetNonce() uint64
+	GetFee() uint64
+	Size() int
+}
+
+type entry[T Txn] struct {
+	txn   T
+	size  int
+	seq   uint64
+	added time.Time
+	index int // in the age heap
+	tail  int // in the tail heap, -1 when not the last of its sender
+}
+
+// higherFeeRate reports whether e pays more fee per byte than other.
+func (e *entry[T]) higherFeeRate(other *entry[T]) bool {
+	xHi, xLo := bits.Mul64(e.txn.GetFee(), uint64(other.size))
+	yHi, yLo := bits.Mul64(other.txn.GetFee(), uint64(e.size))
+	return xHi > yHi || (xHi == yHi && xLo > yLo)
+}
+
+// ageHeap orders the entries of the pool oldest first.
+type ageHeap[T Txn] []*entry[T]
+
+func (h ageHeap[T]) Len() int           { return len(h) }
+func (h ageHeap[T]) Less(i, j int) bool { return h[i].added.Before(h[j].added) }
+func (h ageHeap[T]) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].index = i
+	h[j].index = j
+}
+
+func (h *ageHeap[T]) Push(x any) {
+	e := x.(*entry[T])
+	e.index = len(*h)
+	*h = append(*h, e)
+}
+
+func (h *ageHeap[T]) Pop() any {
+	old := *h
+	e := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return e
+}
+
+// tailHeap orders the last transactions of every sender lowest fee rate
+// first, the order they are evicted in.
+type tailHeap[T Txn] []*entry[T]
+
+func (h tailHeap[T]) Len() int           { return len(h) }
+func (h tailHeap[T]) Less(i, j int) bool { return h[j].higherFeeRate(h[i]) }
+func (h tailHeap[T]) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].tail = i
+	h[j].tail = j
+}
+
+func (h *tailHeap[T]) Push(x any) {
+	e := x.(*entry[T])
+	e.tail = len(*h)
+	*h = append(*h, e)
+}
+
+func (h *tailHeap[T]) Pop() any {
+	old := *h
+	e := old[len(old)-1]
+	*h = old[:len(old)-1]
+	e.tail = -1
+	return e
+}
+
+// headHeap orders the queues of the senders best fee rate of their first
+// transaction first.
+type headHeap[T Txn] [][]*entry[T]
+
+func (h headHeap[T]) Len() int           { return len(h) }
+func (h headHeap[T]) Less(i, j int) bool { return h[i][0].higherFeeRate(h[j][0]) }
+func (h headHeap[T]) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
+func (h *headHeap[T]) Push(x any)        { *h = append(*h, x.([]*entry[T])) }
+
+func (h *headHeap[T]) Pop() any {
+	old := *h
+	queue := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return queue
+}
+
+type Stats struct {
+	Count      int    `json:"count"`
+	MaxCount   int    `json:"max_count"`
+	Senders    int    `json:"senders"`
+	Bytes      int    `json:"bytes"`
+	TotalFees  uint64 `json:"total_fees"`
+	MinFee     uint64 `json:"min_fee"`
+	MaxFee     uint64 `json:"max_fee"`
+	OldestAge  int64  `json:"oldest_age"` // In seconds
+	TxnTTL     int64  `json:"txn_ttl"`    // In seconds
+	Evicted    uint64 `json:"evicted"`
+	Expired    uint64 `json:"expired"`
+	Replaced   uint64 `json:"replaced"`
+	TotalAdded uint64 `json:"total_added"`
+}
+
+// Pool holds the transactions waiting for a block, indexed by hash and by
+// sender. The transactions of a sender always have consecutive nonces, so
+// evicting or expiring one also drops the sender's later ones. The totals of
+// Stats are kept up to date as transactions come and go.
+type Pool[T Txn] struct {
+	mu       sync.RWMutex
+	maxCount int
+	ttl      time.Duration
+	seq      uint64
+	byHash   map[string]*entry[T]
+	bySender map[string][]*entry[T] // in nonce order
+	byAge    ageHeap[T]
+	byTail   tailHeap[T]
+	byFee    map[uint64]int // number of transactions paying each fee
+	changes  uint64
+	stats    Stats
+}
+
+func New[T Txn](maxCount int, ttl time.Duration) *Pool[T] {
+	p := new(Pool[T])
+	p.maxCount = maxCount
+	p.ttl = ttl
+	p.byHash = map[string]*entry[T]{}
+	p.bySender = map[string][]*entry[T]{}
+	p.byAge = ageHeap[T]{}
+	p.byTail = tailHeap[T]{}
+	p.byFee = map[uint64]int{}
+	return p
+}
+
+// setQueue makes queue the transactions of sender and keeps its last one in
+// the tail heap.
+func (p *Pool[T]) setQueue(sender string, queue []*entry[T]) {
+	if old := p.bySender[sender]; len(old) > 0 && old[len(old)-1].tail >= 0 {
+		heap.Remove(&p.byTail, old[len(old)-1].tail)
+	}
+	if len(queue) == 0 {
+		delete(p.bySender, sender)
+		return
+	}
+	p.bySender[sender] = queue
+	heap.Push(&p.byTail, queue[len(queue)-1])
+}
+
+// track counts e in the totals of the pool.
+func (p *Pool[T]) track(e *entry[T]) {
+	fee := e.txn.GetFee()
+	p.stats.Bytes += e.size
+	p.stats.TotalFees += fee
+	if len(p.byFee) == 0 || fee < p.stats.MinFee {
+		p.stats.MinFee = fee
+	}
+	if len(p.byFee) == 0 || fee > p.stats.MaxFee {
+		p.stats.MaxFee = fee
+	}
+	p.byFee[fee]++
+	heap.Push(&p.byAge, e)
+}
+
+// untrack takes e out of the totals of the pool. Only when the last
+// transaction paying the lowest or highest fee leaves are the distinct fees
+// looked at again.
+func (p *Pool[T]) untrack(e *entry[T]) {
+	fee := e.txn.GetFee()
+	p.stats.Bytes -= e.size
+	p.stats.TotalFees -= fee
+	heap.Remove(&p.byAge, e.index)
+
+	p.byFee[fee]--
+	if p.byFee[fee] > 0 {
+		return
+	}
+	delete(p.byFee, fee)
+	if fee != p.stats.MinFee && fee != p.stats.MaxFee {
+		return
+	}
+	p.stats.MinFee, p.stats.MaxFee = 0, 0
+	first := true
+	for other := range p.byFee {
+		if first || other < p.stats.MinFee {
+			p.stats.MinFee = other
+		}
+		if first || other > p.stats.MaxFee {
+			p.stats.MaxFee = other
+		}
+		first = false
+	}
+}
+
+// outbids reports whether a fee of bid may replace one of fee: it must be
+// higher by at least MEMPOOL_MIN_FEE_BUMP percent.
+func outbids(bid uint64, fee uint64) bool {
+	x := new(big.Int).Mul(new(big.Int).SetUint64(bid), big.NewInt(100))
+	y := new(big.Int).Mul(new(big.Int).SetUint64(fee), big.NewInt(100+constants.MEMPOOL_MIN_FEE_BUMP))
+	return bid > fee && x.Cmp(y) >= 0
+}
+
+// Add puts txn in the pool. A transaction with the nonce of one of its
+// sender's pending transactions replaces it if it outbids its fee. When the
+// pool is full the last transaction of the sender with the lowest fee rate is
+// evicted, provided txn pays a better rate. The transactions taken out of the
+// pool are returned.
+func (p *Pool[T]) Add(txn T, now time.Time) (replaced []T, evicted []T, err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	replaced, evicted, err = p.add(txn, now)
+	if err == nil && len(replaced) == 0 {
+		p.stats.TotalAdded++
+	}
+	return replaced, evicted, err
+}
+
+// add puts txn in the pool as added at the time added. See Add.
+func (p *Pool[T]) add(txn T, added time.Time) (replaced []T, evicted []T, err error) {
+	if _, ok := p.byHash[txn.GetHash()]; ok {
+		return nil, nil, ErrKnown
+	}
+
+	queue := p.bySender[txn.GetSender()]
+	for i, e := range queue {
+		if e.txn.GetNonce() != txn.GetNonce() {
+			continue
+		}
+		if !outbids(txn.GetFee(), e.txn.GetFee()) {
+			return nil, nil, ErrReplacementUnderpriced
+		}
+
+		delete(p.byHash, e.txn.GetHash())
+		p.untrack(e)
+		replacement := &entry[T]{txn: txn, size: txn.Size(), seq: e.seq, added: added, tail: -1}
+		queue = append([]*entry[T]{}, queue...)
+		queue[i] = replacement
+		p.setQueue(txn.GetSender(), queue)
+		p.byHash[txn.GetHash()] = replacement
+		p.track(replacement)
+		p.stats.Replaced++
+		p.changes++
+		return []T{e.txn}, nil, nil
+	}
+
+	if len(queue) > 0 && txn.GetNonce() != queue[len(queue)-1].txn.GetNonce()+1 {
+		return nil, nil, ErrNonceGap
+	}
+
+	e := &entry[T]{txn: txn, size: txn.Size(), added: added, tail: -1}
+	if len(p.byHash) >= p.maxCount {
+		// the last transaction of a sender goes, so the nonces left stay
+		// consecutive
+		var victim *entry[T]
+		if len(p.byTail) > 0 {
+			victim = p.byTail[0]
+		}
+		if victim == nil || !e.higherFeeRate(victim) || victim.txn.GetSender() == txn.GetSender() {
+			return nil, nil, ErrPoolFull
+		}
+		p.remove(victim)
+		evicted = append(evicted, victim.txn)
+		p.stats.Evicted++
+	}
+
+	p.seq++
+	e.seq = p.seq
+	p.byHash[txn.GetHash()] = e
+	p.track(e)
+	p.setQueue(txn.GetSender(), append(p.bySender[txn.GetSender()], e))
+	p.changes++
+
+	return nil, evicted, nil
+}
+
+// remove takes e and the later transactions of its sender out of the pool
+// and returns them.
+func (p *Pool[T]) remove(e *entry[T]) []T {
+	sender := e.txn.GetSender()
+	queue := p.bySender[sender]
+
+	p.changes++
+	removed := []T{}
+	for i, other := range queue {
+		if other == e {
+			for _, dropped := range queue[i:] {
+				delete(p.byHash, dropped.txn.GetHash())
+				p.untrack(dropped)
+				removed = append(removed, dropped.txn)
+			}
+			queue = queue[:i]
+			break
+		}
+	}
+
+	p.setQueue(sender, queue)
+	return removed
+}
+
+// Remove takes the transaction with hash out of the pool, leaving the other
+// transactions of its sender in place. Used once it is in a block.
+func (p *Pool[T]) Remove(hash string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	e, ok := p.byHash[hash]
+	if !ok {
+		return
+	}
+	delete(p.byHash, hash)
+	p.untrack(e)
+	p.changes++
+
+	sender := e.txn.GetSender()
+	queue := []*entry[T]{}
+	for _, other := range p.bySender[sender] {
+		if other != e {
+			queue = append(queue, other)
+		}
+	}
+	p.setQueue(sender, queue)
+}
+
+// Drop takes the transaction with hash out of the pool with the later
+// transactions of its sender, which cannot go without it, and returns them.
+func (p *Pool[T]) Drop(hash string) []T {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	e, ok := p.byHash[hash]
+	if !ok {
+		return []T{}
+	}
+	return p.remove(e)
+}
+
+// Expire drops the transactions that waited longer than the TTL, with the
+// later transactions of their sender, and returns them.
+func (p *Pool[T]) Expire(now time.Time) []T {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	expired := []T{}
+	for _, queue := range p.bySender {
+		for _, e := range queue {
+			if now.Sub(e.added) > p.ttl {
+				expired = append(expired, p.remove(e)...)
+				break
+			}
+		}
+	}
+	p.stats.Expired += uint64(len(expired))
+	return expired
+}
+
+// Requeue puts back txns, taken out of blocks that left the chain, ahead of
+// the pending transactions and drops the pending transactions for which drop
+// is true. The pending transactions keep the time they were added, and none
+// of them count as added again.
+func (p *Pool[T]) Requeue(txns []T, drop func(T) bool, now time.Time) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	pending := p.sorted()
+	p.reset()
+	for _, txn := range txns {
+		p.add(txn, now)
+	}
+	for _, e := range pending {
+		if !drop(e.txn) {
+			p.add(e.txn, e.added)
+		}
+	}
+}
+
+// Reset empties the pool.
+func (p *Pool[T]) Reset() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.reset()
+}
+
+func (p *Pool[T]) reset() {
+	p.byHash = map[string]*entry[T]{}
+	p.bySender = map[string][]*entry[T]{}
+	p.byAge = ageHeap[T]{}
+	p.byTail = tailHeap[T]{}
+	p.byFee = map[uint64]int{}
+	p.stats.Bytes, p.stats.TotalFees, p.stats.MinFee, p.stats.MaxFee = 0, 0, 0, 0
+	p.changes++
+}
+
+// Changes counts the updates of the pool, it tells whether the pool changed
+// since it was last looked at.
+func (p *Pool[T]) Changes() uint64 {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	return p.changes
+}
+
+func (p *Pool[T]) Get(hash string) (T, bool) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	e, ok := p.byHash[hash]
+	if !ok {
+		var none T
+		return none, false
+	}
+	return e.txn, true
+}
+
+func (p *Pool[T]) Has(hash string) bool {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	_, ok := p.byHash[hash]
+	return ok
+}
+
+func (p *Pool[T]) Len() int {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	return len(p.byHash)
+}
+
+func (p *Pool[T]) sorted() []*entry[T] {
+	entries := make([]*entry[T], 0, len(p.byHash))
+	for _, e := range p.byHash {
+		entries = append(entries, e)
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].seq < entries[j].seq
+	})
+	return entries
+}
+
+// Txns returns every transaction in the order they arrived. The transactions
+// of a sender come in nonce order.
+func (p *Pool[T]) Txns() []T {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	txns := []T{}
+	for _, e := range p.sorted() {
+		txns = append(txns, e.txn)
+	}
+	return txns
+}
+
+// ByFeeRate returns every transaction, the next transaction of the sender
+// paying the best fee rate first.
+func (p *Pool[T]) ByFeeRate() []T {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	heads := headHeap[T]{}
+	for _, queue := range p.bySender {
+		heads = append(heads, queue)
+	}
+	heap.Init(&heads)
+
+	txns := make([]T, 0, len(p.byHash))
+	for len(heads) > 0 {
+		queue := heads[0]
+		txns = append(txns, queue[0].txn)
+		if len(queue) > 1 {
+			heads[0] = queue[1:]
+			heap.Fix(&heads, 0)
+		} else {
+			heap.Pop(&heads)
+		}
+	}
+	return txns
+}
+
+// BySender returns the transactions of sender in nonce order.
+func (p *Pool[T]) BySender(sender string) []T {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	txns := []T{}
+	for _, e := range p.bySender[sender] {
+		txns = append(txns, e.txn)
+	}
+	return txns
+}
+
+// List returns up to limit transactions accepted by filter that arrived after
+// cursor, and the cursor of the next page, zero on the last page.
+func (p *Pool[T]) List(filter func(T) bool, cursor uint64, limit int) ([]T, uint64) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	txns := []T{}
+	for _, e := range p.sorted() {
+		if e.seq <= cursor || !filter(e.txn) {
+			continue
+		}
+		if len(txns) == limit {
+			return txns, cursor
+		}
+		txns = append(txns, e.txn)
+		cursor = e.seq
+	}
+	return txns, 0
+}
+
+func (p *Pool[T]) Stats(now time.Time) Stats {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	stats := p.stats
+	stats.Count = len(p.byHash)
+	stats.MaxCount = p.maxCount
+	stats.Senders = len(p.bySender)
+	stats.TxnTTL = int64(p.ttl / time.Second)
+	if len(p.byAge) > 0 {
+		stats.OldestAge = int64(now.Sub(p.byAge[0].added) / time.Second)
+	}
+	return stats
+}
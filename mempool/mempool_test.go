@@ -0,0 +1,229 @@
+package mempool
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+type testTxn struct {
+	hash   string
+	sender string
+	nonce  uint64
+	fee    uint64
+	size   int
+}
+
+func (t *testTxn) GetHash() string   { return t.hash }
+func (t *testTxn) GetSender() string { return t.sender }
+func (t *testTxn) GetNonce() uint64  { return t.nonce }
+func (t *testTxn) GetFee() uint64    { return t.fee }
+func (t *testTxn) Size() int         { return t.size }
+
+func txn(sender string, nonce uint64, fee uint64) *testTxn {
+	return &testTxn{fmt.Sprintf("%s-%d-%d", sender, nonce, fee), sender, nonce, fee, 100}
+}
+
+func TestReplacementMustOutbidByTheMinimumBump(t *testing.T) {
+	tests := []struct {
+		name    string
+		fee     uint64
+		bid     uint64
+		wantErr error
+	}{
+		{"higher by one", 100, 101, ErrReplacementUnderpriced},
+		{"just under the bump", 100, 109, ErrReplacementUnderpriced},
+		{"at the bump", 100, 110, nil},
+		{"equal zero fees", 0, 0, ErrReplacementUnderpriced},
+		{"over a zero fee", 0, 1, nil},
+		{"huge fees", 1 << 63, 1<<63 + 1<<60, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := New[*testTxn](10, time.Hour)
+			now := time.Now()
+			_, _, err := p.Add(txn("a", 0, tt.fee), now)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			bid := txn("a", 0, tt.bid)
+			bid.hash += "-replacement"
+			replaced, _, err := p.Add(bid, now)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Add() error = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr == nil && len(replaced) != 1 {
+				t.Fatalf("Add() replaced %d transactions, want 1", len(replaced))
+			}
+		})
+	}
+}
+
+func TestStatsFollowTheTransactions(t *testing.T) {
+	p := New[*testTxn](3, time.Hour)
+	start := time.Now()
+
+	want := func(count int, bytes int, totalFees uint64, minFee uint64, maxFee uint64) {
+		t.Helper()
+		stats := p.Stats(start)
+		if stats.Count != count || stats.Bytes != bytes || stats.TotalFees != totalFees || stats.MinFee != minFee || stats.MaxFee != maxFee {
+			t.Fatalf("Stats() = %+v, want count %d, bytes %d, total fees %d, fees %d to %d", stats, count, bytes, totalFees, minFee, maxFee)
+		}
+	}
+
+	p.Add(txn("a", 0, 10), start)
+	p.Add(txn("a", 1, 20), start.Add(time.Second))
+	p.Add(txn("b", 0, 5), start.Add(2*time.Second))
+	want(3, 300, 35, 5, 20)
+
+	// replacing the highest fee
+	p.Add(txn("a", 1, 30), start.Add(3*time.Second))
+	want(3, 300, 45, 5, 30)
+
+	// evicting the lowest fee rate
+	_, evicted, err := p.Add(txn("c", 0, 50), start.Add(4*time.Second))
+	if err != nil || len(evicted) != 1 {
+		t.Fatalf("Add() evicted %d transactions, error %v", len(evicted), err)
+	}
+	want(3, 300, 90, 10, 50)
+
+	p.Remove(txn("a", 0, 10).hash)
+	want(2, 200, 80, 30, 50)
+
+	if age := p.Stats(start.Add(10 * time.Second)).OldestAge; age != 7 {
+		t.Fatalf("OldestAge = %d, want 7", age)
+	}
+
+	dropped := p.Expire(start.Add(time.Hour + 4*time.Second))
+	if len(dropped) != 1 {
+		t.Fatalf("Expire() dropped %d transactions, want 1", len(dropped))
+	}
+	want(1, 100, 50, 50, 50)
+
+	p.Reset()
+	want(0, 0, 0, 0, 0)
+}
+
+func TestRequeueKeepsTheTimesAdded(t *testing.T) {
+	p := New[*testTxn](10, time.Hour)
+	start := time.Now()
+	p.Add(txn("a", 1, 10), start)
+	p.Add(txn("b", 0, 10), start.Add(time.Second))
+
+	// a's nonce 0 left the chain, b's transaction made it into the new one
+	p.Requeue([]*testTxn{txn("a", 0, 10)}, func(included *testTxn) bool {
+		return included.sender == "b"
+	}, start.Add(time.Minute))
+
+	txns := p.Txns()
+	if len(txns) != 2 || txns[0].nonce != 0 || txns[1].nonce != 1 {
+		t.Fatalf("Txns() = %v, want a's transactions in nonce order", txns)
+	}
+	stats := p.Stats(start.Add(time.Minute))
+	if stats.OldestAge != 60 || stats.TotalAdded != 2 {
+		t.Fatalf("Stats() = %+v, want the pending transaction a minute old and 2 added", stats)
+	}
+}
+
+func TestByFeeRateKeepsTheNonceOrderOfEachSender(t *testing.T) {
+	p := New[*testTxn](10, time.Hour)
+	now := time.Now()
+	p.Add(txn("a", 0, 10), now)
+	p.Add(txn("a", 1, 90), now)
+	p.Add(txn("b", 0, 50), now)
+	big := txn("c", 0, 60)
+	big.size = 1000
+	p.Add(big, now)
+
+	want := []string{"b-0-50", "a-0-10", "a-1-90", "c-0-60"}
+	txns := p.ByFeeRate()
+	if len(txns) != len(want) {
+		t.Fatalf("ByFeeRate() returned %d transactions, want %d", len(txns), len(want))
+	}
+	for i, txn := range txns {
+		if txn.hash != want[i] {
+			t.Fatalf("transaction %d is %s, want %s", i, txn.hash, want[i])
+		}
+	}
+}
+
+func TestFullPoolEvictsTheLowestFeeRateLastTransaction(t *testing.T) {
+	p := New[*testTxn](3, time.Hour)
+	now := time.Now()
+	p.Add(txn("a", 0, 5), now)
+	p.Add(txn("a", 1, 40), now)
+	p.Add(txn("b", 0, 20), now)
+
+	// a's nonce 0 pays least but a's nonce 1 cannot go without it
+	_, evicted, err := p.Add(txn("c", 0, 30), now)
+	if err != nil || len(evicted) != 1 || evicted[0].hash != "b-0-20" {
+		t.Fatalf("Add() evicted %v, error %v, want b's transaction", evicted, err)
+	}
+
+	// the lowest rate now is c's, which a rate of 30 does not beat
+	_, _, err = p.Add(txn("d", 0, 30), now)
+	if !errors.Is(err, ErrPoolFull) {
+		t.Fatalf("Add() error = %v, want ErrPoolFull", err)
+	}
+	_, evicted, err = p.Add(txn("d", 0, 31), now)
+	if err != nil || len(evicted) != 1 || evicted[0].hash != "c-0-30" {
+		t.Fatalf("Add() evicted %v, error %v, want c's transaction", evicted, err)
+	}
+
+	// a sender does not evict its own transactions
+	p.Remove("a-0-5")
+	p.Add(txn("e", 0, 1), now)
+	_, _, err = p.Add(txn("e", 1, 100), now)
+	if !errors.Is(err, ErrPoolFull) {
+		t.Fatalf("Add() error = %v, want ErrPoolFull", err)
+	}
+}
+
+func TestExpireDropsTheLaterTransactionsOfTheSender(t *testing.T) {
+	p := New[*testTxn](10, time.Minute)
+	start := time.Now()
+	p.Add(txn("a", 0, 1), start)
+	p.Add(txn("a", 1, 1), start.Add(50*time.Second))
+	p.Add(txn("b", 0, 1), start.Add(30*time.Second))
+	p.Add(txn("b", 1, 1), start.Add(40*time.Second))
+
+	// a transaction as old as the TTL stays
+	if expired := p.Expire(start.Add(time.Minute)); len(expired) != 0 {
+		t.Fatalf("Expire() = %v at the TTL, want nothing", expired)
+	}
+
+	expired := p.Expire(start.Add(time.Minute + time.Second))
+	if len(expired) != 2 || expired[0].hash != "a-0-1" || expired[1].hash != "a-1-1" {
+		t.Fatalf("Expire() = %v, want both of a's transactions", expired)
+	}
+	if p.Len() != 2 || !p.Has("b-0-1") || !p.Has("b-1-1") {
+		t.Fatalf("the pool holds %v, want b's transactions", p.Txns())
+	}
+
+	expired = p.Expire(start.Add(2 * time.Minute))
+	if len(expired) != 2 || p.Len() != 0 {
+		t.Fatalf("Expire() = %v, leaving %d, want the pool emptied", expired, p.Len())
+	}
+	if stats := p.Stats(start); stats.Expired != 4 {
+		t.Fatalf("Stats().Expired = %d, want 4", stats.Expired)
+	}
+}
+
+func TestFullPoolEvictsByFeeRateRatherThanFee(t *testing.T) {
+	p := New[*testTxn](2, time.Hour)
+	now := time.Now()
+	large := &testTxn{"a-0-50", "a", 0, 50, 1000}
+	p.Add(large, now)
+	p.Add(txn("b", 0, 10), now)
+
+	// 20 for 100 bytes beats 50 for 1000 bytes
+	_, evicted, err := p.Add(txn("c", 0, 20), now)
+	if err != nil || len(evicted) != 1 || evicted[0] != large {
+		t.Fatalf("Add() evicted %v, error %v, want the large transaction", evicted, err)
+	}
+	if stats := p.Stats(now); stats.Evicted != 1 || stats.Bytes != 200 {
+		t.Fatalf("stats = %+v, want one eviction and 200 bytes", stats)
+	}
+}
@@ -0,0 +1,104 @@
+package merkle
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"errors"
+)
+
+// Leaves and inner nodes are hashed with different prefixes so an inner
+// node can never be passed off as a leaf.
+const (
+	leafPrefix = 0x00
+	nodePrefix = 0x01
+)
+
+type ProofStep struct {
+	Hash []byte `json:"hash"`
+	Left bool   `json:"left"` // Hash is the left sibling
+}
+
+func hashLeaf(data []byte) []byte {
+	sum := sha256.Sum256(append([]byte{leafPrefix}, data...))
+	return sum[:]
+}
+
+func hashNode(left, right []byte) []byte {
+	bs := make([]byte, 0, 1+len(left)+len(right))
+	bs = append(bs, nodePrefix)
+	bs = append(bs, left...)
+	bs = append(bs, right...)
+	sum := sha256.Sum256(bs)
+	return sum[:]
+}
+
+// nextLevel pairs up the nodes of a level. An odd node out is carried up
+// unchanged rather than paired with itself.
+func nextLevel(level [][]byte) [][]byte {
+	next := [][]byte{}
+	for i := 0; i < len(level); i += 2 {
+		if i+1 < len(level) {
+			next = append(next, hashNode(level[i], level[i+1]))
+		} else {
+			next = append(next, level[i])
+		}
+	}
+	return next
+}
+
+func leafLevel(leaves [][]byte) [][]byte {
+	level := make([][]byte, len(leaves))
+	for i, leaf := range leaves {
+		level[i] = hashLeaf(leaf)
+	}
+	return level
+}
+
+// Root returns the root of the tree over leaves, the hash of nothing when
+// there are no leaves.
+func Root(leaves [][]byte) []byte {
+	if len(leaves) == 0 {
+		sum := sha256.Sum256([]byte{})
+		return sum[:]
+	}
+
+	level := leafLevel(leaves)
+	for len(level) > 1 {
+		level = nextLevel(level)
+	}
+	return level[0]
+}
+
+// Proof returns the sibling hashes from the leaf at index up to the root.
+func Proof(leaves [][]byte, index int) ([]ProofStep, error) {
+	if index < 0 || index >= len(leaves) {
+		return nil, errors.New("leaf index out of range")
+	}
+
+	proof := []ProofStep{}
+	level := leafLevel(leaves)
+	for len(level) > 1 {
+		if index%2 == 1 {
+			proof = append(proof, ProofStep{level[index-1], true})
+		} else if index+1 < len(level) {
+			proof = append(proof, ProofStep{level[index+1], false})
+		}
+		level = nextLevel(level)
+		index /= 2
+	}
+
+	return proof, nil
+}
+
+// Verify checks that leaf is part of the tree with the given root.
+func Verify(leaf []byte, proof []ProofStep, root []byte) bool {
+	hash := hashLeaf(leaf)
+	for _, step := range proof {
+		if step.Left {
+			hash = hashNode(step.Hash, hash)
+		} else {
+			hash = hashNode(hash, step.Hash)
+		}
+	}
+	return bytes.Equal(hash, root)
+}
@@ -0,0 +1,56 @@
+package merkle
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+)
+
+func leavesOf(n int) [][]byte {
+	leaves := [][]byte{}
+	for i := 0; i < n; i++ {
+		leaves = append(leaves, []byte(fmt.Sprintf("txn-%d", i)))
+	}
+	return leaves
+}
+
+func TestProofVerifiesEveryLeaf(t *testing.T) {
+	for n := 1; n <= 9; n++ {
+		leaves := leavesOf(n)
+		root := Root(leaves)
+		for i, leaf := range leaves {
+			proof, err := Proof(leaves, i)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !Verify(leaf, proof, root) {
+				t.Fatalf("leaf %d of %d does not verify", i, n)
+			}
+			if Verify([]byte("other"), proof, root) {
+				t.Fatalf("another leaf verifies in place of leaf %d of %d", i, n)
+			}
+		}
+	}
+}
+
+func TestRoot(t *testing.T) {
+	leaves := leavesOf(3)
+	if !bytes.Equal(Root(leaves), Root(leavesOf(3))) {
+		t.Fatal("the root of the same leaves changed")
+	}
+	if bytes.Equal(Root(leaves), Root(leaves[:2])) {
+		t.Fatal("dropping a leaf kept the root")
+	}
+	// an odd leaf is carried up, not paired with a copy of itself
+	if bytes.Equal(Root(leaves), Root(append(leavesOf(3), leaves[2]))) {
+		t.Fatal("duplicating the last leaf kept the root")
+	}
+	// an inner node cannot stand in for the two leaves under it
+	inner := hashNode(hashLeaf(leaves[0]), hashLeaf(leaves[1]))
+	if bytes.Equal(Root(leaves[:2]), Root([][]byte{inner})) {
+		t.Fatal("an inner node passed as a leaf")
+	}
+	if _, err := Proof(leaves, 3); err == nil {
+		t.Fatal("Proof() accepted an index out of range")
+	}
+}
@@ -2,14 +2,11 @@
 # go back
 cd ../
 
-# remove the file
+# remove the data directory
 rm -rf 5000
 
-# Define the file path
-file_path="constants/constants.go"
-
-# Use sed to search and replace the value
-sed -i 's/\(BLOCKCHAIN_DB_PATH\s*=\s*"\)[^\/]*\/evodb"/\15000\/evodb"/' "$file_path"
+# create the data directory and the genesis block
+go run main.go chain init -datadir 5000
 
 # run the file
-go run main.go chain -port 5000 -miners_address evochain3dd025e8fec7eda7cdd012ddde9c8e978ee7fa33
\ No newline at end of file
+go run main.go chain -datadir 5000 -port 5000 -miners_address evochain3dd025e8fec7eda7cdd012ddde9c8e978ee7fa33
@@ -2,14 +2,8 @@
 # go back
 cd ../
 
-# remove the file
+# remove the data directory
 rm -rf 5003
 
-# Define the file path
-file_path="constants/constants.go"
-
-# Use sed to search and replace the value
-sed -i 's/\(BLOCKCHAIN_DB_PATH\s*=\s*"\)[^\/]*\/evodb"/\15003\/evodb"/' "$file_path"
-
 # run the file
-go run main.go chain -port 5003 -miners_address evochain42d40be8b315e31dac50a4daf93ce366b1c62668 -remote_node http://127.0.0.1:5000
\ No newline at end of file
+go run main.go chain -datadir 5003 -port 5003 -miners_address evochain42d40be8b315e31dac50a4daf93ce366b1c62668 -remote_node http://127.0.0.1:5000
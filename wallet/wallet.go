@@ -4,15 +4,16 @@ import (
 	"crypto/ecdsa"
 	"crypto/elliptic"
 	"crypto/rand"
-	"crypto/sha256"
-	"encoding/json"
+	"errors"
 	"fmt"
 	"math/big"
 
+	"github.com/sap200/evochain/address"
 	"github.com/sap200/evochain/blockchain"
-	"github.com/sap200/evochain/constants"
 )
 
+var ErrChainMismatch = errors.New("transaction chain ID does not match the chain of the node")
+
 type Wallet struct {
 	PrivateKey *ecdsa.PrivateKey `json:"private_key"`
 	PublicKey  *ecdsa.PublicKey  `json:"public_key"`
@@ -53,22 +54,21 @@ func (w *Wallet) GetPrivateKeyHex() string {
 }
 
 func (w *Wallet) GetPublicKeyHex() string {
-	return fmt.Sprintf("0x%x%x", w.PublicKey.X, w.PublicKey.Y)
+	return address.PublicKeyHex(w.PublicKey)
 }
 
 func (w *Wallet) GetAddress() string {
-	hash := sha256.Sum256([]byte(w.GetPublicKeyHex()[2:]))
-	hex := fmt.Sprintf("%x", hash[:])
-	address := constants.ADDRESS_PREFIX + hex[len(hex)-40:]
-	return address
+	return address.FromPublicKey(w.PublicKey)
 }
 
-func (w *Wallet) GetSignedTxn(unsignedTxn blockchain.Transaction) (*blockchain.Transaction, error) {
-	bs, err := json.Marshal(unsignedTxn)
-	if err != nil {
-		return nil, err
+// GetSignedTxn signs the transaction, provided it is for chainId, the chain
+// of the node it is sent to.
+func (w *Wallet) GetSignedTxn(unsignedTxn blockchain.Transaction, chainId uint64) (*blockchain.Transaction, error) {
+	if unsignedTxn.ChainId != chainId {
+		return nil, ErrChainMismatch
 	}
-	hash := sha256.Sum256(bs)
+
+	hash := unsignedTxn.SigningHash()
 
 	sig, err := ecdsa.SignASN1(rand.Reader, w.PrivateKey, hash[:])
 	if err != nil {
@@ -81,6 +81,9 @@ func (w *Wallet) GetSignedTxn(unsignedTxn blockchain.Transaction) (*blockchain.T
 	signedTxn.Data = unsignedTxn.Data
 	signedTxn.Status = unsignedTxn.Status
 	signedTxn.Value = unsignedTxn.Value
+	signedTxn.Fee = unsignedTxn.Fee
+	signedTxn.ChainId = unsignedTxn.ChainId
+	signedTxn.Nonce = unsignedTxn.Nonce
 	signedTxn.Timestamp = unsignedTxn.Timestamp
 	signedTxn.TransactionHash = unsignedTxn.TransactionHash
 	// new fields
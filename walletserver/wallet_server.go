@@ -19,12 +19,14 @@ import (
 type WalletServer struct {
 	Port                  uint64 `json:"port"`
 	BlockchainNodeAddress string `json:"blockchain_node_addres"`
+	ChainId               uint64 `json:"chain_id"` // Zero signs for the chain of the node
 }
 
-func NewWalletServer(port uint64, blockchainNodeAddress string) *WalletServer {
+func NewWalletServer(port uint64, blockchainNodeAddress string, chainId uint64) *WalletServer {
 	ws := new(WalletServer)
 	ws.Port = port
 	ws.BlockchainNodeAddress = blockchainNodeAddress
+	ws.ChainId = chainId
 	return ws
 }
 
@@ -84,6 +86,77 @@ func (ws *WalletServer) GetTotalCryptoFromWallet(w http.ResponseWriter, req *htt
 	}
 }
 
+// forwardQuery answers req with the response of the node to the same query
+// on path, keeping the node's status code.
+func (ws *WalletServer) forwardQuery(w http.ResponseWriter, req *http.Request, path string) {
+	ourURL := fmt.Sprintf("%s%s?%s", ws.BlockchainNodeAddress, path, req.URL.RawQuery)
+	resp, err := http.Get(ourURL)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	defer resp.Body.Close()
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	w.WriteHeader(resp.StatusCode)
+	w.Write(data)
+}
+
+func (ws *WalletServer) GetWalletTransactions(w http.ResponseWriter, req *http.Request) {
+	w.Header().Add("Content-Type", "application/json")
+	if req.Method == http.MethodGet {
+		ws.forwardQuery(w, req, "/address_txns")
+	} else {
+		http.Error(w, "Invalid Method", http.StatusBadRequest)
+	}
+}
+
+func (ws *WalletServer) GetTransaction(w http.ResponseWriter, req *http.Request) {
+	w.Header().Add("Content-Type", "application/json")
+	if req.Method == http.MethodGet {
+		ws.forwardQuery(w, req, "/txn")
+	} else {
+		http.Error(w, "Invalid Method", http.StatusBadRequest)
+	}
+}
+
+func (ws *WalletServer) GetReceipt(w http.ResponseWriter, req *http.Request) {
+	w.Header().Add("Content-Type", "application/json")
+	if req.Method == http.MethodGet {
+		ws.forwardQuery(w, req, "/receipt")
+	} else {
+		http.Error(w, "Invalid Method", http.StatusBadRequest)
+	}
+}
+
+func (ws *WalletServer) GetNextNonce(address string) (uint64, error) {
+	params := url.Values{}
+	params.Add("address", address)
+	ourURL := fmt.Sprintf("%s?%s", ws.BlockchainNodeAddress+"/nonce", params.Encode())
+	resp, err := http.Get(ourURL)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return 0, err
+	}
+
+	var x struct {
+		Nonce uint64 `json:"nonce"`
+	}
+	err = json.Unmarshal(data, &x)
+	if err != nil {
+		return 0, err
+	}
+
+	return x.Nonce, nil
+}
+
 func (ws *WalletServer) SendTxnToTheBlockchain(w http.ResponseWriter, req *http.Request) {
 	w.Header().Add("Content-Type", "application/json")
 	if req.Method == http.MethodPost {
@@ -104,9 +177,41 @@ func (ws *WalletServer) SendTxnToTheBlockchain(w http.ResponseWriter, req *http.
 
 		wallet1 := wallet.NewWalletFromPrivateKeyHex(privateKey)
 
-		myTxn := blockchain.NewTransaction(wallet1.GetAddress(), txn1.To, txn1.Value, []byte{})
+		nonce, err := ws.GetNextNonce(wallet1.GetAddress())
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		// an explicit nonce replaces the pending transaction holding it
+		if req.URL.Query().Get("nonce") != "" {
+			nonce, err = strconv.ParseUint(req.URL.Query().Get("nonce"), 10, 64)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+		}
+
+		tip, err := blockchain.FetchTip(ws.BlockchainNodeAddress)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		// the chain to sign for is the one asked for, else the node's
+		chainId := txn1.ChainId
+		if chainId == 0 {
+			chainId = ws.ChainId
+		}
+		if chainId == 0 {
+			chainId = tip.ChainId
+		}
+
+		myTxn := blockchain.NewTransaction(wallet1.GetAddress(), txn1.To, txn1.Value, txn1.Fee, nonce, []byte{})
+		myTxn.ChainId = chainId
+		myTxn.TransactionHash = myTxn.Hash()
 		myTxn.Status = constants.PENDING
-		newTxn, err := wallet1.GetSignedTxn(*myTxn)
+		newTxn, err := wallet1.GetSignedTxn(*myTxn, tip.ChainId)
 		if err != nil {
 			http.Error(w, err.Error(), http.StatusBadRequest)
 			return
@@ -132,7 +237,9 @@ func (ws *WalletServer) SendTxnToTheBlockchain(w http.ResponseWriter, req *http.
 		}
 		defer resp.Body.Close()
 
-		io.WriteString(w, string(resultBs))
+		// pass the node's verdict on the transaction through
+		w.WriteHeader(resp.StatusCode)
+		w.Write(resultBs)
 	} else {
 		http.Error(w, "Invalid Method", http.StatusBadRequest)
 	}
@@ -143,6 +250,9 @@ func (ws *WalletServer) Start() {
 	http.HandleFunc("/wallet_balance", ws.GetTotalCryptoFromWallet)
 	http.HandleFunc("/create_new_wallet", ws.CreateNewWallet)
 	http.HandleFunc("/send_signed_txn", ws.SendTxnToTheBlockchain)
+	http.HandleFunc("/wallet_txns", ws.GetWalletTransactions)
+	http.HandleFunc("/txn", ws.GetTransaction)
+	http.HandleFunc("/receipt", ws.GetReceipt)
 	log.Println("Starting wallet server at port:", ws.Port)
 	err := http.ListenAndServe("127.0.0.1:"+strconv.Itoa(int(ws.Port)), nil)
 	if err != nil {